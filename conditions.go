@@ -0,0 +1,106 @@
+package js2svg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// keywords of the constraints depending on the presence of a property. The
+// draft-04 dependencies mixes both: arrays of required names and schemas.
+var dependentKeywords = []string{"dependentRequired", "dependentSchemas", "dependencies"}
+
+// composeConditions connects the object with boxes listing the constraints
+// applied under a condition: the then and else schemas of an if, and the
+// schemas or required properties depending on the presence of a property.
+func (p *parser) composeConditions(m map[string]interface{}, o *Object) error {
+	if cond, ok := m["if"].(map[string]interface{}); ok {
+		label := conditionLabel(cond)
+		if err := p.composeCondition(m, o, "then", "if "+label, m["then"], "then"); err != nil {
+			return err
+		}
+		if err := p.composeCondition(m, o, "else", "unless "+label, m["else"], "else"); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range dependentKeywords {
+		for _, dep := range mapToIter(GetObject(m, key)) {
+			schema := dep.Value
+			if names, ok := dep.Value.([]interface{}); ok {
+				schema = map[string]interface{}{"required": names}
+			}
+			label := fmt.Sprintf("if %s present", dep.Key)
+			if err := p.composeCondition(m, o, "dependent", label, schema, key, dep.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// composeCondition adds the box of a conditional schema to the object. The box
+// lists the properties of the schema, and the properties of the object it makes
+// required. Schemas without anything to list are skipped.
+func (p *parser) composeCondition(m map[string]interface{}, o *Object, condition, label string, schema interface{}, tokens ...string) error {
+	if _, ok := schema.(bool); ok || schema == nil {
+		return nil
+	}
+	defer p.enter(schema, tokens...)()
+	sm, ok := schema.(map[string]interface{})
+	if !ok {
+		p.report("the %s schema is not an object", condition)
+		return nil
+	}
+
+	child := &Object{Name: o.Name, Condition: condition}
+	child.Description, _ = sm["description"].(string)
+	properties := GetObject(sm, "properties")
+	if err := p.parseFields(sm, mapToIter(properties), child); err != nil {
+		return err
+	}
+	for _, v := range GetSlice(sm, "required") {
+		name, ok := v.(string)
+		if !ok || properties[name] != nil {
+			continue
+		}
+		setScalarProperty(name, cardinality(true, false), GetObject(GetObject(m, "properties"), name), child)
+	}
+	if err := p.composeConditions(sm, child); err != nil {
+		return err
+	}
+
+	if len(child.Properties) > 0 || len(child.ComposedOf) > 0 {
+		o.ComposedOf = append(o.ComposedOf, Composition{Name: label, Kind: Conditional, Object: child})
+	}
+	return nil
+}
+
+// conditionLabel describes the schema of an if, eg. "SchemeName = IBAN and Amount present"
+func conditionLabel(m map[string]interface{}) string {
+	var parts []string
+	properties := GetObject(m, "properties")
+	for _, prop := range mapToIter(properties) {
+		pm, _ := prop.Value.(map[string]interface{})
+		values := enumValues(pm)
+		switch {
+		case pm["const"] != nil:
+			parts = append(parts, fmt.Sprintf("%s = %v", prop.Key, pm["const"]))
+		case len(values) > 0:
+			parts = append(parts, fmt.Sprintf("%s in (%s)", prop.Key, strings.Join(values, ", ")))
+		case pm["pattern"] != nil:
+			parts = append(parts, fmt.Sprintf("%s matches %v", prop.Key, pm["pattern"]))
+		default:
+			parts = append(parts, fmt.Sprintf("%s is valid", prop.Key))
+		}
+	}
+	for _, v := range GetSlice(m, "required") {
+		if name, ok := v.(string); ok && properties[name] == nil {
+			parts = append(parts, fmt.Sprintf("%s present", name))
+		}
+	}
+
+	if len(parts) == 0 {
+		return "condition"
+	}
+	return strings.Join(parts, " and ")
+}
@@ -0,0 +1,82 @@
+package js2svg
+
+import "fmt"
+
+// Severity of a Diagnostic
+type Severity int
+
+const (
+	// Error fails the parsing, the Diagnostic is returned as the error
+	Error Severity = iota
+	// Warning is recorded in the Diagnostics of the Diagram, the offending node
+	// is skipped or rendered partially
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic is a problem found in a document, located by the JSON Pointer of the
+// offending node. The errors returned while parsing a document are *Diagnostic.
+type Diagnostic struct {
+	Severity Severity
+	// Pointer is the uri of the document (empty for the source document) with
+	// a JSON Pointer fragment, eg. "#/components/schemas/Amount/properties/Currency"
+	Pointer string
+	Message string
+}
+
+// Error implements the error interface
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s at '%s': %s", d.Severity, d.Pointer, d.Message)
+}
+
+// errorAt returns an Error Diagnostic for the node at pointer
+func errorAt(pointer, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Severity: Error,
+		Pointer:  pointer,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// enter moves the location of the parser to the node at tokens relative to the
+// current one, or to the definition of m if it was inlined in place of a $ref.
+// The returned function moves it back.
+func (p *parser) enter(m interface{}, tokens ...string) func() {
+	prev := p.location
+	if mm, ok := m.(map[string]interface{}); ok && mm[refKey] != nil {
+		p.location, _ = mm[refKey].(string)
+	} else {
+		p.location = prev + formatPointer(tokens)
+	}
+	return func() { p.location = prev }
+}
+
+// warn records a Warning at the current location
+func (p *parser) warn(format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Severity: Warning,
+		Pointer:  p.location,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// report records a problem with the schema at the current location: a Warning,
+// or an Error in Strict mode which fails the parsing once the walk is over
+func (p *parser) report(format string, args ...interface{}) {
+	p.warn(format, args...)
+	if p.opts.Strict {
+		p.diagnostics[len(p.diagnostics)-1].Severity = Error
+	}
+}
+
+// reportAt reports a problem with the node at tokens relative to the current location
+func (p *parser) reportAt(tokens []string, format string, args ...interface{}) {
+	defer p.enter(nil, tokens...)()
+	p.report(format, args...)
+}
@@ -0,0 +1,130 @@
+package js2svg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DocumentKind is the specification a document follows
+type DocumentKind int
+
+const (
+	// JSONSchema is a plain JSON Schema document, with definitions in $defs or definitions
+	JSONSchema DocumentKind = iota
+	// Swagger2 is a Swagger 2.0 document, with definitions in definitions
+	Swagger2
+	// OpenAPI30 is an OpenAPI 3.0.x document, with definitions in components.schemas
+	OpenAPI30
+	// OpenAPI31 is an OpenAPI 3.1.x document, with definitions in components.schemas
+	OpenAPI31
+)
+
+func (k DocumentKind) String() string {
+	switch k {
+	case Swagger2:
+		return "Swagger 2.0"
+	case OpenAPI30:
+		return "OpenAPI 3.0"
+	case OpenAPI31:
+		return "OpenAPI 3.1"
+	}
+	return "JSON Schema"
+}
+
+// DetectDocument recognises the specification of an unmarshalled document
+// by its swagger or openapi version field
+func DetectDocument(doc map[string]interface{}) DocumentKind {
+	if version := fmt.Sprint(doc["swagger"]); strings.HasPrefix(version, "2") {
+		return Swagger2
+	}
+
+	version, _ := doc["openapi"].(string)
+	switch {
+	case strings.HasPrefix(version, "3.0"):
+		return OpenAPI30
+	case strings.HasPrefix(version, "3."):
+		return OpenAPI31
+	}
+	return JSONSchema
+}
+
+// schemaContainers are the locations of the reusable schemas in any kind of document
+var schemaContainers = [][]string{
+	{"components", "schemas"},
+	{"definitions"},
+	{"$defs"},
+}
+
+// containers returns the locations of the reusable schemas in a document of
+// the kind, the preferred one first
+func (k DocumentKind) containers() [][]string {
+	switch k {
+	case Swagger2:
+		return [][]string{{"definitions"}}
+	case OpenAPI30, OpenAPI31:
+		return [][]string{{"components", "schemas"}}
+	}
+	// documents without a version may be fragments of an OpenAPI document as well
+	return [][]string{{"$defs"}, {"definitions"}, {"components", "schemas"}}
+}
+
+// SchemaPointer returns the JSON Pointer of the reusable schema with the given
+// name, looking in the definitions, components.schemas or $defs of the document
+// depending on its kind.
+func SchemaPointer(doc map[string]interface{}, name string) (string, bool) {
+	tokens, found := schemaTokens(doc, name)
+	if !found {
+		return "", false
+	}
+	return formatPointer(tokens), true
+}
+
+func schemaTokens(doc map[string]interface{}, name string) ([]string, bool) {
+	for _, container := range DetectDocument(doc).containers() {
+		tokens := appendToken(container, name)
+		if _, found := getTokens(doc, tokens); found {
+			return tokens, true
+		}
+	}
+	return nil, false
+}
+
+// isBareName reports whether the object path is the name of a schema rather than
+// a path within the document
+func isBareName(objectPath string) bool {
+	return objectPath != "" && !strings.ContainsAny(objectPath, "/#"+internalDivider)
+}
+
+// relocate maps the tokens of a reference to the schemas of another kind of
+// document (eg. "#/definitions/Pet" in an OpenAPI 3 document) to the location of the
+// schema in doc. It returns false if the tokens don't point to a reusable schema
+// or there is no such schema in doc.
+func relocate(doc map[string]interface{}, tokens []string) ([]string, bool) {
+	for _, container := range schemaContainers {
+		if len(tokens) <= len(container) || !hasPrefix(tokens, container) {
+			continue
+		}
+		name, rest := tokens[len(container)], tokens[len(container)+1:]
+		target, found := schemaTokens(doc, name)
+		if !found {
+			return nil, false
+		}
+		for _, token := range rest {
+			target = appendToken(target, token)
+		}
+		if _, found := getTokens(doc, target); found {
+			return target, true
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+func hasPrefix(tokens, prefix []string) bool {
+	for i := range prefix {
+		if tokens[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
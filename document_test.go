@@ -0,0 +1,91 @@
+package js2svg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectDocument(t *testing.T) {
+	for doc, kind := range map[string]DocumentKind{
+		`swagger: "2.0"`:   Swagger2,
+		`openapi: 3.0.3`:   OpenAPI30,
+		`openapi: "3.1.0"`: OpenAPI31,
+		`$schema: "https://json-schema.org/draft/2020-12/schema"`: JSONSchema,
+		`type: object`: JSONSchema,
+	} {
+		m, err := unmarshalSrc(strings.NewReader(doc))
+		require.NoError(t, err)
+		assert.Equal(t, kind, DetectDocument(m), doc)
+	}
+}
+
+func TestParseBareNames(t *testing.T) {
+	for _, tc := range []struct {
+		doc     string
+		pointer string
+	}{
+		{`
+swagger: "2.0"
+definitions:
+  Pet:
+    type: object
+    properties:
+      Owner:
+        $ref: '#/definitions/Person'
+  Person:
+    type: object
+    properties:
+      Name:
+        type: string
+`, "/definitions/Pet"},
+		{`
+openapi: 3.0.3
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        Owner:
+          $ref: '#/definitions/Person'
+    Person:
+      type: object
+      properties:
+        Name:
+          type: string
+`, "/components/schemas/Pet"},
+		{`
+$defs:
+  Pet:
+    type: object
+    properties:
+      Owner:
+        $ref: '#/$defs/Person'
+  Person:
+    type: object
+    properties:
+      Name:
+        type: string
+`, "/$defs/Pet"},
+	} {
+		m, err := unmarshalSrc(strings.NewReader(tc.doc))
+		require.NoError(t, err)
+		pointer, ok := SchemaPointer(m, "Pet")
+		assert.True(t, ok)
+		assert.Equal(t, tc.pointer, pointer)
+
+		d, err := ParseToDiagram(strings.NewReader(tc.doc), "Pet")
+		require.NoError(t, err, tc.pointer)
+		assert.Equal(t, "Pet", d.Root.Name)
+		require.Len(t, d.Root.ComposedOf, 1)
+		owner := d.Root.ComposedOf[0].Object
+		assert.Equal(t, "Owner", owner.Name)
+		require.Len(t, owner.Properties, 1)
+		assert.Equal(t, "Name", owner.Properties[0].Name)
+	}
+
+	_, err := ParseToDiagram(strings.NewReader(`openapi: 3.0.3`), "Pet")
+	assert.EqualError(t, err, "error at '#/Pet': object not found")
+}
@@ -0,0 +1,66 @@
+package js2svg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// noLoader keeps the fuzz targets off the file system and the network
+var noLoader = LoaderFunc(func(uri string) (io.ReadCloser, error) {
+	return nil, errors.New("external references are not loaded")
+})
+
+// fuzzSeeds are the documents and object paths the fuzz targets start from
+func fuzzSeeds(f *testing.F) {
+	example, err := ioutil.ReadFile("test-example.yaml")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range []string{
+		"components.schemas.OBStandingOrder6Basic",
+		"components.schemas.OBReadTransaction6",
+		"/components/schemas/OBAccount6",
+	} {
+		f.Add(example, path)
+	}
+
+	f.Add([]byte(allOfDoc), "components.schemas.Dog")
+	f.Add([]byte(`{"type": "object", "properties": {"a": {"$ref": "#"}}}`), "")
+	f.Add([]byte(`{"type": "object", "required": [1, null], "properties": {"a": {"enum": [{}, []]}}}`), "")
+	f.Add([]byte(`{"type": "object", "properties": {"a": {"type": "array", "items": [true, {"type": 1}]}}}`), "")
+	f.Add([]byte(`{"properties": {"a": {"additionalProperties": 1, "patternProperties": {"x": []}}}}`), "")
+	f.Add([]byte(`{"required": [{}], "allOf": [{"required": [{"a": 1}]}]}`), "")
+}
+
+// FuzzParseToDiagram checks the parser returns an error for malformed documents
+// instead of panicking, in both modes
+func FuzzParseToDiagram(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, doc []byte, path string) {
+		for _, opts := range []Options{
+			{Loader: noLoader},
+			{Loader: noLoader, Strict: true},
+			{Loader: noLoader, SeparateAllOf: true, SharedDefinitions: true, EnumBoxes: true},
+		} {
+			ParseToDiagramWithOptions(bytes.NewReader(doc), path, opts)
+		}
+	})
+}
+
+// FuzzRender renders the diagrams of the documents the parser accepts
+func FuzzRender(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, doc []byte, path string) {
+		opts := Options{Loader: noLoader, SharedDefinitions: true, EnumBoxes: true, InlineTypes: true}
+		d, err := ParseToDiagramWithOptions(bytes.NewReader(doc), path, opts)
+		if err != nil {
+			return
+		}
+		if err := d.Render(ioutil.Discard); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
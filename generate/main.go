@@ -7,6 +7,9 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/urfave/cli"
 	"github.com/zgiber/js2svg"
@@ -24,12 +27,45 @@ func main() {
 			},
 			&cli.StringFlag{
 				Name:  "path",
-				Usage: "The path of the selected object within the JSON document. (eg.: 'components.schemas.myAwesomeSchema')",
+				Usage: "The path of the selected object within the JSON document, dotted or as a JSON Pointer. (eg.: 'components.schemas.myAwesomeSchema' or '/components/schemas/myAwesomeSchema'), or the name of a schema defined in the document (eg.: 'myAwesomeSchema').",
 			},
 			&cli.StringFlag{
 				Name:  "out",
 				Usage: "The file path for the SVG file to be written. If a file with the same name already exists it will be overwritten. If empty, stdout is used.",
 			},
+			&cli.BoolFlag{
+				Name:  "shared",
+				Usage: "Render each referenced definition once, connected to all the objects using it.",
+			},
+			&cli.BoolFlag{
+				Name:  "types",
+				Usage: "Show the types of the properties in the class boxes (name: type [cardinality]).",
+			},
+			&cli.BoolFlag{
+				Name:  "enums",
+				Usage: "Render the values of enumerated properties as separate enumeration boxes.",
+			},
+			&cli.BoolFlag{
+				Name:  "separate-allof",
+				Usage: "Render the referenced allOf members as separate base objects instead of merging them.",
+			},
+			&cli.BoolFlag{
+				Name:  "operations",
+				Usage: "Render the request and response bodies of every operation in the paths of an OpenAPI document, one SVG file each in the directory given by out (the working directory if empty). The path is ignored.",
+			},
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "Fail on malformed or unsupported schemas instead of skipping them with a warning.",
+			},
+			&cli.BoolFlag{
+				Name:  "hide-deprecated",
+				Usage: "Leave out the deprecated properties and objects instead of rendering them struck through.",
+			},
+			&cli.StringFlag{
+				Name:  "view",
+				Value: "auto",
+				Usage: "Properties of the schemas shared by requests and responses: request (without readOnly), response (without writeOnly), all, or auto (all, except the bodies of operations which are shown as sent or received).",
+			},
 		},
 	}
 
@@ -63,10 +99,33 @@ func run(ctx *cli.Context) error {
 	}
 	defer src.Close()
 
-	d, err := js2svg.ParseToDiagram(src, ctx.String("path"))
+	view, err := js2svg.ParseView(ctx.String("view"))
+	if err != nil {
+		return err
+	}
+
+	opts := js2svg.Options{
+		View:              view,
+		SeparateAllOf:     ctx.Bool("separate-allof"),
+		SharedDefinitions: ctx.Bool("shared"),
+		InlineTypes:       ctx.Bool("types"),
+		EnumBoxes:         ctx.Bool("enums"),
+		Strict:            ctx.Bool("strict"),
+		HideDeprecated:    ctx.Bool("hide-deprecated"),
+		BaseURI:           u.String(),
+	}
+
+	if ctx.Bool("operations") {
+		return renderOperations(src, ctx.String("out"), opts)
+	}
+
+	d, err := js2svg.ParseToDiagramWithOptions(src, ctx.String("path"), opts)
 	if err != nil {
 		return err
 	}
+	for _, diag := range d.Diagnostics {
+		log.Println(&diag)
+	}
 
 	dst := os.Stdout
 	if len(ctx.String("out")) > 0 {
@@ -79,6 +138,44 @@ func run(ctx *cli.Context) error {
 	return d.Render(dst)
 }
 
+// renderOperations writes the diagrams of the operations to files named after
+// their titles, eg. post_payments_createpayment_201_application_json.svg
+func renderOperations(src io.Reader, dir string, opts js2svg.Options) error {
+	operations, err := js2svg.ParseOperations(src, opts)
+	if err != nil {
+		return err
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	for _, op := range operations {
+		for _, diag := range op.Diagram.Diagnostics {
+			log.Printf("%s: %v", op.Title(), &diag)
+		}
+
+		name := strings.Trim(fileName.ReplaceAllString(op.Title(), "_"), "_")
+		dst, err := os.Create(filepath.Join(dir, strings.ToLower(name)+".svg"))
+		if err != nil {
+			return err
+		}
+		if err := op.Diagram.Render(dst); err != nil {
+			dst.Close()
+			return err
+		}
+		if err := dst.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileName matches the runs of characters replaced in the file names of the operations
+var fileName = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
 func openFileSrc(path string) (io.ReadCloser, error) {
 	return os.Open(path)
 }
@@ -82,7 +82,9 @@ func renderDiagrams(name, url string, diagrams []string) error {
 	}
 	defer schema.Close()
 
-	parsedSchema, err := js2svg.ParseToMap(schema, "components.schemas")
+	// the whole document, the diagrams are looked up by name in its definitions
+	// (components.schemas of OpenAPI 3, definitions of Swagger 2.0)
+	parsedSchema, err := js2svg.ParseToMapWithOptions(schema, "", js2svg.Options{BaseURI: url})
 	if err != nil {
 		return err
 	}
@@ -98,16 +100,19 @@ func renderDiagrams(name, url string, diagrams []string) error {
 
 	// write the rendered diagrams to files
 	for _, diagramName := range diagrams {
-		objectSchema, ok := parsedSchema[diagramName].(map[string]interface{})
+		pointer, ok := js2svg.SchemaPointer(parsedSchema, diagramName)
 		if !ok {
-			log.Printf("can't find object in %s: 'components.schemas.%s'", name, diagramName)
+			log.Printf("can't find object in %s: '%s'", name, diagramName)
 			continue
 		}
 
-		diagram, err := js2svg.MakeDiagram(objectSchema, diagramName)
+		diagram, err := js2svg.MakeDiagram(js2svg.GetObject(parsedSchema, pointer), pointer)
 		if err != nil {
 			return err
 		}
+		for _, diag := range diagram.Diagnostics {
+			log.Printf("%s: %v", diagramName, &diag)
+		}
 
 		dst, err := os.Create(path.Join(dir, diagramName+".svg"))
 		if err != nil {
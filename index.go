@@ -0,0 +1,103 @@
+package js2svg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// location of a value: the uri of the document and the pointer tokens within it
+type location struct {
+	uri    string
+	tokens []string
+}
+
+// index of the schema identifiers ($id and $anchor) found in the loaded documents,
+// so references to them resolve without fetching anything
+type index struct {
+	ids     map[string]location // absolute uri without fragment -> schema
+	anchors map[string]location // absolute uri with the anchor as fragment -> schema
+}
+
+func newIndex() *index {
+	return &index{
+		ids:     map[string]location{},
+		anchors: map[string]location{},
+	}
+}
+
+// keywords holding plain values instead of schemas, their $id fields are not identifiers
+var valueKeywords = map[string]bool{
+	"enum":     true,
+	"const":    true,
+	"default":  true,
+	"example":  true,
+	"examples": true,
+}
+
+// add the document retrieved from uri with the identifiers of its schemas
+func (x *index) add(uri string, doc map[string]interface{}) {
+	x.ids[uri] = location{uri: uri}
+	x.walk(uri, location{uri: uri}, doc)
+}
+
+func (x *index) walk(base string, loc location, v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		base = x.identify(base, loc, t)
+		for k, value := range t {
+			if !valueKeywords[k] {
+				x.walk(base, location{loc.uri, appendToken(loc.tokens, k)}, value)
+			}
+		}
+
+	case []interface{}:
+		for i, value := range t {
+			x.walk(base, location{loc.uri, appendToken(loc.tokens, fmt.Sprint(i))}, value)
+		}
+	}
+}
+
+// identify records the identifiers of the schema at loc and returns the base uri
+// of its subschemas
+func (x *index) identify(base string, loc location, m map[string]interface{}) string {
+	if id, ok := m["$id"].(string); ok {
+		if uri, fragment, err := resolveRefURI(base, id); err == nil {
+			if strings.HasPrefix(id, "#") {
+				// a plain name fragment, the draft-06 style of an anchor
+				x.anchors[base+"#"+fragment] = loc
+			} else {
+				base = uri
+				if _, exists := x.ids[base]; !exists {
+					x.ids[base] = loc
+				}
+			}
+		}
+	}
+	if anchor, ok := m["$anchor"].(string); ok {
+		x.anchors[base+"#"+anchor] = loc
+	}
+	return base
+}
+
+// baseURI returns the base uri of the value at tokens within the document
+// retrieved from uri, set by the $id of the enclosing schemas
+func baseURI(uri string, doc map[string]interface{}, tokens []string) string {
+	base := uri
+	var v interface{} = doc
+	for i := 0; ; i++ {
+		if m, ok := v.(map[string]interface{}); ok {
+			if id, ok := m["$id"].(string); ok && !strings.HasPrefix(id, "#") {
+				if resolved, _, err := resolveRefURI(base, id); err == nil {
+					base = resolved
+				}
+			}
+		}
+		if i == len(tokens) {
+			return base
+		}
+		var found bool
+		if v, found = getTokens(v, tokens[i:i+1]); !found {
+			return base
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package js2svg
 
+import (
+	"fmt"
+	"strings"
+)
+
 var (
 	gapWidth  = 6.0
 	gapHeight = 1.0
@@ -9,54 +14,295 @@ var (
 type Object struct {
 	Name        string
 	Description string
+	Variant     string   // "" | "oneOf" | "anyOf"
+	Condition   string   // "then" | "else" | "dependent" for the constraints applied under a condition
+	Values      []string // the literals of an «enumeration» box
+	Deprecated  bool
 	Properties  []Property
 	ComposedOf  []Composition
-	Position    Position // calculated ... of the top left corner of the rendered object
+	// BackReference is set on stubs standing for a recursive reference to an object
+	// which is already in the diagram.
+	BackReference *Object
+	Position      Position // calculated ... of the top left corner of the rendered object
+
+	// the composed objects placed next to this one. An object composed by several
+	// parents (shared definition) is placed next to the first one only.
+	layoutChildren []*Object
+	inlineTypes    bool // render property lines as "name: type [cardinality]"
 }
 
 // Property (may rename to field)
 type Property struct {
 	Name         string
 	Description  string
-	Relationship string // "0..1" | "1..1" | "1..*"
+	Relationship string // "0..1" | "1..1" | "1..*" | "0..3" ...
+
+	// constraints of the value, as defined in the schema
+	Type       string // "string" | "string | null" ... (the type of the items for arrays)
+	Format     string
+	Enum       []string
+	Pattern    string
+	MinLength  *float64
+	MaxLength  *float64
+	Minimum    *float64
+	Maximum    *float64
+	Default    interface{}
+	Example    interface{}
+	Deprecated bool
+	ReadOnly   bool
+	WriteOnly  bool
+}
+
+// Label is the line of the property rendered in the class box. The type is shown
+// for unions, and for entries named by a key or an index ("[key: string]", "[0]")
+// where the name alone says nothing about the value.
+func (p Property) Label() string {
+	if p.Type != "" && (strings.Contains(p.Type, "|") || strings.HasPrefix(p.Name, "[")) {
+		return fmt.Sprintf("%s: %s [%s]%s", p.Name, p.Type, p.Relationship, p.modifier())
+	}
+	return fmt.Sprintf("%s [%s]%s", p.Name, p.Relationship, p.modifier())
+}
+
+// InlineLabel is the UML style line of the property: "name: type [cardinality]",
+// with the format if there is one, eg. "Created: string(date-time) [1..1]"
+func (p Property) InlineLabel() string {
+	typ := p.Type
+	if typ == "" {
+		typ = "any"
+	}
+	if p.Format != "" {
+		typ = fmt.Sprintf("%s(%s)", typ, p.Format)
+	}
+	return fmt.Sprintf("%s: %s [%s]%s", p.Name, typ, p.Relationship, p.modifier())
+}
+
+// Access is "readOnly" or "writeOnly" for the properties present in the
+// responses or the requests only, "" otherwise
+func (p Property) Access() string {
+	switch {
+	case p.ReadOnly:
+		return "readOnly"
+	case p.WriteOnly:
+		return "writeOnly"
+	}
+	return ""
+}
+
+// modifier is the UML property modifier of the access, eg. " {readOnly}"
+func (p Property) modifier() string {
+	if access := p.Access(); access != "" {
+		return fmt.Sprintf(" {%s}", access)
+	}
+	return ""
+}
+
+// Tooltip describes the property with all of its constraints
+func (p Property) Tooltip() string {
+	info := strings.Builder{}
+	line := func(key string, value interface{}) {
+		if s := fmt.Sprint(value); value != nil && s != "" {
+			info.WriteString(fmt.Sprintf("%s: %s\n", key, s))
+		}
+	}
+	number := func(key string, value *float64) {
+		if value != nil {
+			line(key, *value)
+		}
+	}
+
+	line("Type", p.Type)
+	line("Format", p.Format)
+	number("MinLength", p.MinLength)
+	number("MaxLength", p.MaxLength)
+	number("Minimum", p.Minimum)
+	number("Maximum", p.Maximum)
+	line("Description", p.Description)
+	if len(p.Enum) > 0 {
+		info.WriteString("Values:\n")
+		for _, v := range p.Enum {
+			info.WriteString(fmt.Sprintf(" - %s\n", v))
+		}
+	}
+	line("Pattern", p.Pattern)
+	line("Default", p.Default)
+	line("Example", p.Example)
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{
+		{p.Deprecated, "Deprecated"},
+		{p.ReadOnly, "Read only"},
+		{p.WriteOnly, "Write only"},
+	} {
+		if flag.set {
+			info.WriteString(flag.name + "\n")
+		}
+	}
+
+	return info.String()
 }
 
 // Composition represents a connection between two class boxes
 type Composition struct {
-	Relationship string // "0..1" | "1..1" | "1..*"
+	Name         string // the property holding the object, when it's named differently
+	Value        string // the value of the discriminator property (Name) selecting a subtype
+	Relationship string // "0..1" | "1..1" | "1..*" | "0..3" ...
+	Access       string // "readOnly" | "writeOnly" for the objects of a single direction
+	Kind         CompositionKind
 	Object       *Object
 }
 
+// CompositionKind determines how a connection is drawn
+type CompositionKind int
+
+const (
+	// Composed is the default: the object is a part of its parent
+	Composed CompositionKind = iota
+	// Inheritance connects an object with a base schema it extends (allOf)
+	Inheritance
+	// OneOf connects a variant object with one of its exclusive alternatives
+	OneOf
+	// AnyOf connects a variant object with one of its alternatives
+	AnyOf
+	// Enumeration connects an object with the «enumeration» box of the values
+	// allowed in one of its properties (named by the composition)
+	Enumeration
+	// Map connects a dictionary-like object with the type of its values, the
+	// Name of the composition describes the key
+	Map
+	// Subtype connects a base object with one of the subtypes of its discriminator
+	// mapping (generalisation), selected by the Value of the property Name
+	Subtype
+	// Conditional connects an object with the constraints applied to it under a
+	// condition (if/then/else, dependentRequired, dependentSchemas), the Name of the
+	// composition describes the condition
+	Conditional
+)
+
 // Position is pretty self explanatory
 type Position struct {
 	X float64
 	Y float64
 }
 
+// objects returns the objects reachable from o (including o) once each
+func (o *Object) objects() []*Object {
+	var all []*Object
+	seen := map[*Object]bool{}
+	var visit func(*Object)
+	visit = func(o *Object) {
+		if seen[o] {
+			return
+		}
+		seen[o] = true
+		all = append(all, o)
+		for _, c := range o.ComposedOf {
+			visit(c.Object)
+		}
+	}
+	visit(o)
+	return all
+}
+
+// fieldNames adds the names of the fields of o to names: its properties, the
+// properties holding composed objects, and the fields of its separate bases
+func (o *Object) fieldNames(names map[string]bool) {
+	for _, p := range o.Properties {
+		names[p.Name] = true
+	}
+	for _, c := range o.ComposedOf {
+		switch c.Kind {
+		case Composed:
+			name := c.Name
+			if name == "" {
+				name = c.Object.Name
+			}
+			names[name] = true
+		case Inheritance:
+			c.Object.fieldNames(names)
+		}
+	}
+}
+
+// hasDeprecated reports whether any of the objects reachable from o, or their
+// properties, are deprecated
+func (o *Object) hasDeprecated() bool {
+	for _, obj := range o.objects() {
+		if obj.Deprecated {
+			return true
+		}
+		for _, p := range obj.Properties {
+			if p.Deprecated {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PropertyLabel is the line of the property rendered in the class box
+func (o *Object) PropertyLabel(p Property) string {
+	if o.inlineTypes {
+		return p.InlineLabel()
+	}
+	return p.Label()
+}
+
+// arrange selects the layout children of the objects reachable from o, so the
+// composed objects make a tree even if some are shared. The objects are visited
+// breadth first, so each is placed next to its shallowest parent. Called once on
+// the root before the diagram is rendered.
+func (o *Object) arrange() {
+	placed := map[*Object]bool{o: true}
+	queue := []*Object{o}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		parent.layoutChildren = nil
+		for _, c := range parent.ComposedOf {
+			if !placed[c.Object] {
+				placed[c.Object] = true
+				parent.layoutChildren = append(parent.layoutChildren, c.Object)
+				queue = append(queue, c.Object)
+			}
+		}
+	}
+}
+
 // this is called once on the root before the diagram is rendered
 func (o *Object) calculateChildPositions() {
-	if len(o.ComposedOf) == 0 {
+	if len(o.layoutChildren) == 0 {
 		return
 	}
 
 	childPosX := o.Position.X + o.Width() + gapWidth
-	for i := 0; i < len(o.ComposedOf); i++ {
+	for i, child := range o.layoutChildren {
 		var posY float64
 		if i == 0 {
 			posY = o.Position.Y
 		} else {
-			prev := o.ComposedOf[i-1]
-			posY = prev.Object.totalHeight() + prev.Object.Position.Y
+			prev := o.layoutChildren[i-1]
+			posY = prev.totalHeight() + prev.Position.Y
 		}
 
-		o.ComposedOf[i].Object.Position = Position{
+		child.Position = Position{
 			childPosX,
 			posY,
 		}
-		o.ComposedOf[i].Object.calculateChildPositions()
+		child.calculateChildPositions()
 	}
 }
 
+// isLayoutChild reports whether the object is placed next to o
+func (o *Object) isLayoutChild(child *Object) bool {
+	for _, c := range o.layoutChildren {
+		if c == child {
+			return true
+		}
+	}
+	return false
+}
+
 // NamePosition returns the postiion where the class name is to be rendered
 func (o *Object) NamePosition() Position {
 	return Position{
@@ -90,32 +336,63 @@ func (o *Object) ConnectorOutPosition() Position {
 	}
 }
 
+// ElementID identifies the rendered class box within the SVG document
+func (o *Object) ElementID() string {
+	return fmt.Sprintf("%s-%v-%v", o.Name, o.Position.X, o.Position.Y)
+}
+
+// Stereotype is shown after the name of the class box, eg. "oneOf" or "enumeration"
+func (o *Object) Stereotype() string {
+	if o.Values != nil {
+		return "enumeration"
+	}
+	if o.Condition != "" {
+		return o.Condition
+	}
+	return o.Variant
+}
+
 // Width of the class box
 func (o *Object) Width() float64 {
 	w := len(o.Name)
+	if s := o.Stereotype(); s != "" {
+		w += len(s) + 3 // " «oneOf»"
+	}
+	if o.BackReference != nil && len(o.BackReference.Name)+4 > w {
+		w = len(o.BackReference.Name) + 4 // "see Name"
+	}
 	for _, p := range o.Properties {
-		l := len(p.Name) + len(p.Relationship) + 3
+		l := len(o.PropertyLabel(p))
 		if l > w {
 			w = l
 		}
 	}
+	for _, v := range o.Values {
+		if len(v) > w {
+			w = len(v)
+		}
+	}
 	return float64(w) * 0.8 // for some reason boxes are too wide by default
 }
 
 // Height of the class box
 func (o *Object) Height() float64 {
-	return float64(len(o.Properties))*1.3 + 3.0 // name, line between name and properties, properties, frames
+	lines := len(o.Properties) + len(o.Values)
+	if o.BackReference != nil {
+		lines++
+	}
+	return float64(lines)*1.3 + 3.0 // name, line between name and properties, properties, frames
 }
 
 // total width of the tree at the widest branch with gaps
 func (o *Object) totalWidth() float64 {
 	w := o.Position.X + o.Width()
-	if len(o.ComposedOf) == 0 {
+	if len(o.layoutChildren) == 0 {
 		return w
 	}
 
-	for _, c := range o.ComposedOf {
-		if childWidth := c.Object.totalWidth(); childWidth > w {
+	for _, c := range o.layoutChildren {
+		if childWidth := c.totalWidth(); childWidth > w {
 			w = childWidth
 		}
 	}
@@ -126,13 +403,13 @@ func (o *Object) totalWidth() float64 {
 // total height of the tree calculated from the lowermost object
 func (o *Object) totalHeight() float64 {
 	// dfs
-	if len(o.ComposedOf) == 0 {
+	if len(o.layoutChildren) == 0 {
 		return o.Height() + gapHeight
 	}
 
 	sumLeafNodesHeight := 0.0
-	for _, childItem := range o.ComposedOf {
-		sumLeafNodesHeight += childItem.Object.totalHeight()
+	for _, child := range o.layoutChildren {
+		sumLeafNodesHeight += child.totalHeight()
 	}
 
 	if o.Height() > sumLeafNodesHeight {
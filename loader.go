@@ -0,0 +1,72 @@
+package js2svg
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Loader fetches the documents referenced by external $ref items.
+// The uri is absolute, without the fragment.
+type Loader interface {
+	Load(uri string) (io.ReadCloser, error)
+}
+
+// LoaderFunc allows using an ordinary function as a Loader
+type LoaderFunc func(uri string) (io.ReadCloser, error)
+
+// Load calls f(uri)
+func (f LoaderFunc) Load(uri string) (io.ReadCloser, error) {
+	return f(uri)
+}
+
+// DefaultLoader is used when no Loader is set in the Options. It opens local files
+// (plain paths or file:// uris) and fetches http:// and https:// uris.
+var DefaultLoader Loader = LoaderFunc(loadFileOrHTTP)
+
+func loadFileOrHTTP(uri string) (io.ReadCloser, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return LoadHTTP(uri)
+	case "file", "":
+		return os.Open(u.Path)
+	default:
+		return nil, fmt.Errorf("unknown scheme in '%s'", uri)
+	}
+}
+
+// httpClient fetches the remote documents, a hanging server fails the reference
+// instead of blocking the parser forever
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// LoadHTTP fetches http:// and https:// uris. It can be used as a Loader
+// (wrapped in LoaderFunc) where local files must not be accessible.
+func LoadHTTP(uri string) (io.ReadCloser, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme in '%s'", uri)
+	}
+
+	resp, err := httpClient.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("remote returned status %v for '%s'", resp.StatusCode, uri)
+	}
+
+	return resp.Body, nil
+}
@@ -3,6 +3,7 @@ package js2svg
 import (
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,6 +41,19 @@ func TestObjectDimensions(t *testing.T) {
 
 }
 
+func TestArrangeSharedObjects(t *testing.T) {
+	root := testObject("root", 1)
+	a, b, x, shared := testObject("a", 1), testObject("b", 1), testObject("x", 1), testObject("shared", 1)
+	root.ComposedOf = []Composition{{Object: a}, {Object: b}}
+	a.ComposedOf = []Composition{{Object: x}}
+	x.ComposedOf = []Composition{{Object: shared}}
+	b.ComposedOf = []Composition{{Object: shared}}
+
+	root.arrange()
+	assert.Empty(t, x.layoutChildren)
+	assert.Equal(t, []*Object{shared}, b.layoutChildren)
+}
+
 func TestParsing(t *testing.T) {
 	// not a real test either...
 	f, err := os.Open("test-example.yaml")
@@ -59,6 +73,29 @@ func TestParsing(t *testing.T) {
 	}
 }
 
+func TestInlineTypes(t *testing.T) {
+	o := &Object{
+		Name: "Payment",
+		Properties: []Property{
+			{Name: "Created", Type: "string", Format: "date-time", Relationship: "1..1"},
+			{Name: "Reference", Type: "string", Relationship: "0..1"},
+		},
+	}
+
+	d := Diagram{Root: o}
+	var buf strings.Builder
+	assert.NoError(t, d.Render(&buf))
+	assert.Contains(t, buf.String(), "Created [1..1]")
+	defaultWidth := o.Width()
+
+	d.InlineTypes = true
+	buf.Reset()
+	assert.NoError(t, d.Render(&buf))
+	assert.Contains(t, buf.String(), "Created: string(date-time) [1..1]")
+	assert.Contains(t, buf.String(), "Reference: string [0..1]")
+	assert.Greater(t, o.Width(), defaultWidth)
+}
+
 func testObject(name string, h int) *Object {
 	o := Object{Name: name, Description: fmt.Sprintf("test object %s", name)}
 	for i := 0; i < h-4; i++ {
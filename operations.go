@@ -0,0 +1,321 @@
+package js2svg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// methods of the operations of a path item, in the order they are rendered
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// OperationDiagram is the diagram of the request body or of a response of an
+// operation, for one of its media types
+type OperationDiagram struct {
+	Method      string // "GET" | "POST" ...
+	Path        string // the path of the operation, eg. "/payments/{id}"
+	OperationID string
+	Status      string // the status code of the response, "" for the request
+	MediaType   string // "" for the box of the parameters or headers
+	Diagram     *Diagram
+}
+
+// Title labels the diagram with the operation and the status code,
+// eg. "POST /payments (CreatePayment) 201 application/json"
+func (o OperationDiagram) Title() string {
+	parts := []string{o.Method, o.Path}
+	if o.OperationID != "" {
+		parts = append(parts, fmt.Sprintf("(%s)", o.OperationID))
+	}
+	switch {
+	case o.Status == "" && o.MediaType == "":
+		parts = append(parts, "parameters")
+	case o.Status == "":
+		parts = append(parts, "request", o.MediaType)
+	case o.MediaType == "":
+		parts = append(parts, o.Status, "headers")
+	default:
+		parts = append(parts, o.Status, o.MediaType)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseOperations renders the request and response bodies of all operations
+// found in the paths of an OpenAPI 3 or Swagger 2.0 document.
+func ParseOperations(src io.Reader, opts Options) ([]OperationDiagram, error) {
+	doc, err := ParseToMapWithOptions(src, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	return MakeOperationDiagrams(doc, opts)
+}
+
+// MakeOperationDiagrams renders the operations of a document parsed with ParseToMap
+// (with the whole document selected). Operations are ordered by path and method,
+// request bodies are rendered in the request view and responses in the response view
+// (unless Options.View says otherwise),
+// bodies which are not objects are skipped.
+func MakeOperationDiagrams(doc map[string]interface{}, opts Options) ([]OperationDiagram, error) {
+	var diagrams []OperationDiagram
+	for _, item := range mapToIter(GetObject(doc, "/paths")) {
+		pathItem, ok := item.Value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, method := range methods {
+			op, ok := pathItem[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			tokens := []string{"paths", item.Key, method}
+			operation := OperationDiagram{
+				Method: strings.ToUpper(method),
+				Path:   item.Key,
+			}
+			operation.OperationID, _ = op["operationId"].(string)
+			add := func(status, mediaType string, d *Diagram) {
+				operation.Status, operation.MediaType, operation.Diagram = status, mediaType, d
+				d.Title = operation.Title()
+				diagrams = append(diagrams, operation)
+			}
+
+			if d := parametersDiagram("Parameters", operationParameters(pathItem, op), opts); d != nil {
+				add("", "", d)
+			}
+			for _, body := range operationBodies(doc, op, tokens) {
+				d, err := makeBodyDiagram(body, opts)
+				if err != nil {
+					return nil, err
+				}
+				if d != nil {
+					add(body.status, body.mediaType, d)
+				}
+			}
+			for _, response := range mapToIter(GetObject(op, "/responses")) {
+				if d := parametersDiagram("Headers", responseHeaders(response.Value), opts); d != nil {
+					add(response.Key, "", d)
+				}
+			}
+		}
+	}
+	return diagrams, nil
+}
+
+// parameterLocations are the values of 'in' of the parameters listed in the box,
+// in the order they are listed
+var parameterLocations = []string{"path", "query", "header", "cookie", "formData"}
+
+// operationParameters returns the parameters of the operation and the ones defined
+// for all operations of the path (unless overridden), except for Swagger 2.0 body parameters
+func operationParameters(pathItem, op map[string]interface{}) []map[string]interface{} {
+	key := func(p map[string]interface{}) string {
+		return fmt.Sprintf("%v:%v", p["in"], p["name"])
+	}
+
+	var params []map[string]interface{}
+	seen := map[string]bool{}
+	for _, list := range [][]interface{}{GetSlice(op, "parameters"), GetSlice(pathItem, "parameters")} {
+		for _, param := range list {
+			pm, ok := param.(map[string]interface{})
+			if !ok || seen[key(pm)] {
+				continue
+			}
+			seen[key(pm)] = true
+			params = append(params, pm)
+		}
+	}
+
+	var sorted []map[string]interface{}
+	for _, in := range parameterLocations {
+		for _, pm := range params {
+			if pm["in"] == in {
+				sorted = append(sorted, pm)
+			}
+		}
+	}
+	return sorted
+}
+
+// responseHeaders returns the headers of a response as parameters
+func responseHeaders(response interface{}) []map[string]interface{} {
+	rm, _ := response.(map[string]interface{})
+	var headers []map[string]interface{}
+	for _, header := range mapToIter(GetObject(rm, "/headers")) {
+		hm, ok := header.Value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		param := map[string]interface{}{"name": header.Key}
+		for k, v := range hm {
+			param[k] = v
+		}
+		headers = append(headers, param)
+	}
+	return headers
+}
+
+// parametersDiagram lists the parameters in a single box, as properties with the
+// type of their schema. The location of the parameter is shown after the name,
+// except for headers of responses. Deprecated parameters and enumerations are
+// handled as the properties of schemas. It returns nil if there is nothing to list.
+func parametersDiagram(name string, params []map[string]interface{}, opts Options) *Diagram {
+	p := &parser{opts: opts, enums: map[string]*Object{}}
+	o := &Object{Name: name}
+	for _, param := range params {
+		// the schema, the first schema of the content, or the parameter itself in Swagger 2.0
+		schema, ok := param["schema"].(map[string]interface{})
+		if content := mapToIter(GetObject(param, "/content")); !ok && len(content) > 0 {
+			media, _ := content[0].Value.(map[string]interface{})
+			schema, ok = media["schema"].(map[string]interface{})
+		}
+		if !ok {
+			schema = param
+		}
+		if p.hidden(param) || p.hidden(schema) {
+			continue
+		}
+
+		label := fmt.Sprint(param["name"])
+		if in, ok := param["in"].(string); ok {
+			label = fmt.Sprintf("%s (%s)", label, in)
+		}
+		required, _ := param["required"].(bool)
+		setScalarProperty(label, cardinality(required, false), schema, o)
+
+		property := &o.Properties[len(o.Properties)-1]
+		values := schema
+		if schemaType(schema) == "array" {
+			values = GetObject(schema, "items")
+			property.Type = typeLabel(values) + "[]"
+		}
+		if description, ok := param["description"].(string); ok {
+			property.Description = description
+		}
+		if deprecated, ok := param["deprecated"].(bool); ok {
+			property.Deprecated = deprecated
+		}
+		p.composeEnumeration(o, label, values)
+	}
+
+	if len(o.Properties) == 0 {
+		return nil
+	}
+	// the types are the point of the box
+	return &Diagram{Root: o, InlineTypes: true}
+}
+
+// body is the schema of a request or response body
+type body struct {
+	status    string
+	mediaType string
+	schema    map[string]interface{}
+	tokens    []string // location of the schema
+}
+
+// operationBodies lists the request body, then the responses sorted by status code
+func operationBodies(doc, op map[string]interface{}, tokens []string) []body {
+	if DetectDocument(doc) == Swagger2 {
+		return swaggerBodies(doc, op, tokens)
+	}
+
+	var bodies []body
+	content := func(status string, m map[string]interface{}, tokens []string) {
+		for _, media := range mapToIter(GetObject(m, "/content")) {
+			schema := GetObject(m, formatPointer([]string{"content", media.Key, "schema"}))
+			if len(schema) > 0 {
+				bodies = append(bodies, body{status, media.Key, schema, joinTokens(tokens, "content", media.Key, "schema")})
+			}
+		}
+	}
+
+	content("", GetObject(op, "/requestBody"), joinTokens(tokens, "requestBody"))
+	for _, response := range mapToIter(GetObject(op, "/responses")) {
+		if rm, ok := response.Value.(map[string]interface{}); ok {
+			content(response.Key, rm, joinTokens(tokens, "responses", response.Key))
+		}
+	}
+	return bodies
+}
+
+// swaggerBodies lists the bodies of a Swagger 2.0 operation: the body parameter
+// and the schemas of the responses, for each media type it consumes or produces
+func swaggerBodies(doc, op map[string]interface{}, tokens []string) []body {
+	mediaTypes := func(key string) []string {
+		types := GetSlice(op, key)
+		if len(types) == 0 {
+			types = GetSlice(doc, key)
+		}
+		var names []string
+		for _, t := range types {
+			if s, ok := t.(string); ok {
+				names = append(names, s)
+			}
+		}
+		if len(names) == 0 {
+			names = []string{"application/json"}
+		}
+		return names
+	}
+
+	var bodies []body
+	for i, param := range GetSlice(op, "parameters") {
+		pm, ok := param.(map[string]interface{})
+		if !ok || pm["in"] != "body" {
+			continue
+		}
+		schema, _ := pm["schema"].(map[string]interface{})
+		for _, mediaType := range mediaTypes("consumes") {
+			bodies = append(bodies, body{"", mediaType, schema, joinTokens(tokens, "parameters", fmt.Sprint(i), "schema")})
+		}
+	}
+
+	for _, response := range mapToIter(GetObject(op, "/responses")) {
+		schema := GetObject(op, formatPointer([]string{"responses", response.Key, "schema"}))
+		for _, mediaType := range mediaTypes("produces") {
+			bodies = append(bodies, body{response.Key, mediaType, schema, joinTokens(tokens, "responses", response.Key, "schema")})
+		}
+	}
+	return bodies
+}
+
+// makeBodyDiagram renders the schema of a body, or the schema of its items if it's
+// an array, in the view of its direction unless another one was chosen. It returns
+// nil if there is nothing to render.
+func makeBodyDiagram(b body, opts Options) (*Diagram, error) {
+	if opts.View == ViewAuto {
+		opts.View = ViewResponse
+		if b.status == "" {
+			opts.View = ViewRequest
+		}
+	}
+
+	schema, tokens := b.schema, b.tokens
+	for schemaType(schema) == "array" {
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		schema, tokens = items, appendToken(tokens, "items")
+	}
+	if schema == nil || !isObjectSchema(schema) && !isVariantSchema(schema) {
+		return nil, nil
+	}
+
+	d, err := MakeDiagramWithOptions(schema, formatPointer(tokens), opts)
+	if err != nil {
+		return nil, err
+	}
+	if ref, ok := schema[refKey].(string); ok {
+		d.Root.Name = refName(ref)
+	} else if b.status == "" {
+		d.Root.Name = "Request"
+	} else {
+		d.Root.Name = "Response" + b.status
+	}
+	return d, nil
+}
+
+// joinTokens returns a new slice of the tokens followed by more
+func joinTokens(tokens []string, more ...string) []string {
+	return append(append([]string{}, tokens...), more...)
+}
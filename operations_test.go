@@ -0,0 +1,322 @@
+package js2svg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOperations(t *testing.T) {
+	doc := `
+openapi: 3.0.3
+paths:
+  /payments:
+    post:
+      operationId: CreatePayment
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Payment'
+      responses:
+        201:
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  Id:
+                    type: string
+        204:
+          description: no content
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/Payment'
+            text/plain:
+              schema:
+                type: string
+components:
+  schemas:
+    Payment:
+      type: object
+      properties:
+        Amount:
+          type: string
+`
+	operations, err := ParseOperations(strings.NewReader(doc), Options{})
+	require.NoError(t, err)
+
+	var titles, roots []string
+	for _, op := range operations {
+		titles = append(titles, op.Title())
+		roots = append(roots, op.Diagram.Root.Name)
+		assert.Equal(t, op.Title(), op.Diagram.Title)
+	}
+	assert.Equal(t, []string{
+		"GET /payments 200 application/json",
+		"POST /payments (CreatePayment) request application/json",
+		"POST /payments (CreatePayment) 201 application/json",
+	}, titles)
+	assert.Equal(t, []string{"Payment", "Payment", "Response201"}, roots)
+
+	var buf strings.Builder
+	require.NoError(t, operations[1].Diagram.Render(&buf))
+	assert.Contains(t, buf.String(), "POST /payments (CreatePayment) request application/json")
+}
+
+func TestParseSwaggerOperations(t *testing.T) {
+	doc := `
+swagger: "2.0"
+produces: [application/xml]
+paths:
+  /pets:
+    post:
+      consumes: [application/json, application/xml]
+      parameters:
+        - name: pet
+          in: body
+          schema:
+            $ref: '#/definitions/Pet'
+      responses:
+        200:
+          schema:
+            $ref: '#/definitions/Pet'
+definitions:
+  Pet:
+    type: object
+    properties:
+      Name:
+        type: string
+`
+	operations, err := ParseOperations(strings.NewReader(doc), Options{})
+	require.NoError(t, err)
+
+	var titles []string
+	for _, op := range operations {
+		titles = append(titles, op.Title())
+		assert.Equal(t, "Pet", op.Diagram.Root.Name)
+	}
+	assert.Equal(t, []string{
+		"POST /pets request application/json",
+		"POST /pets request application/xml",
+		"POST /pets 200 application/xml",
+	}, titles)
+}
+
+func TestParseOperationParameters(t *testing.T) {
+	doc := `
+openapi: 3.0.3
+paths:
+  /payments/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: string
+      - name: x-fapi-interaction-id
+        in: header
+        schema:
+          type: string
+    get:
+      parameters:
+        - name: Authorization
+          in: header
+          required: true
+          schema:
+            type: string
+        - name: fields
+          in: query
+          schema:
+            type: array
+            items:
+              type: string
+        - name: x-fapi-interaction-id
+          in: header
+          description: overridden
+          schema:
+            type: string
+            format: uuid
+      responses:
+        200:
+          headers:
+            x-fapi-interaction-id:
+              schema:
+                type: string
+`
+	operations, err := ParseOperations(strings.NewReader(doc), Options{})
+	require.NoError(t, err)
+	require.Len(t, operations, 2)
+
+	params, headers := operations[0], operations[1]
+	assert.Equal(t, "GET /payments/{id} parameters", params.Title())
+	assert.Equal(t, "GET /payments/{id} 200 headers", headers.Title())
+
+	var labels []string
+	for _, p := range params.Diagram.Root.Properties {
+		labels = append(labels, p.InlineLabel())
+	}
+	assert.Equal(t, []string{
+		"id (path): string [1..1]",
+		"fields (query): string[] [0..1]",
+		"Authorization (header): string [1..1]",
+		"x-fapi-interaction-id (header): string(uuid) [0..1]",
+	}, labels)
+	assert.Equal(t, "overridden", params.Diagram.Root.Properties[3].Description)
+
+	require.Len(t, headers.Diagram.Root.Properties, 1)
+	assert.Equal(t, "x-fapi-interaction-id", headers.Diagram.Root.Properties[0].Name)
+
+	var buf strings.Builder
+	require.NoError(t, params.Diagram.Render(&buf))
+	assert.Contains(t, buf.String(), "Authorization (header): string [1..1]")
+
+	// the options apply to the parameters like to the properties of schemas
+	options := `
+openapi: 3.0.3
+paths:
+  /payments:
+    get:
+      parameters:
+        - name: status
+          in: query
+          schema:
+            type: string
+            enum: [Pending, Done]
+        - name: page
+          in: query
+          deprecated: true
+          schema:
+            type: integer
+      responses:
+        200:
+          headers:
+            x-legacy:
+              schema:
+                type: string
+                deprecated: true
+`
+	operations, err = ParseOperations(strings.NewReader(options), Options{HideDeprecated: true, EnumBoxes: true})
+	require.NoError(t, err)
+	require.Len(t, operations, 1)
+	params = operations[0]
+	require.Len(t, params.Diagram.Root.Properties, 1)
+	assert.Equal(t, "status (query)", params.Diagram.Root.Properties[0].Name)
+	require.Len(t, params.Diagram.Root.ComposedOf, 1)
+	enum := params.Diagram.Root.ComposedOf[0]
+	assert.Equal(t, Enumeration, enum.Kind)
+	assert.Equal(t, []string{"Pending", "Done"}, enum.Object.Values)
+
+	// the parameter itself in Swagger 2.0, the schema of the content in OpenAPI 3
+	parameterLabels := func(doc string) []string {
+		operations, err := ParseOperations(strings.NewReader(doc), Options{})
+		require.NoError(t, err)
+		require.NotEmpty(t, operations)
+		var list []string
+		for _, p := range operations[0].Diagram.Root.Properties {
+			list = append(list, p.InlineLabel())
+		}
+		return list
+	}
+
+	assert.Equal(t, []string{
+		"id (path): integer(int64) [1..1]",
+		"tags (query): string[] [0..1]",
+	}, parameterLabels(`
+swagger: "2.0"
+paths:
+  /pets/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          type: integer
+          format: int64
+        - name: tags
+          in: query
+          type: array
+          items:
+            type: string
+      responses:
+        200:
+          description: ok
+`))
+
+	assert.Equal(t, []string{
+		"filter (query): object [0..1]",
+	}, parameterLabels(`
+openapi: 3.0.3
+paths:
+  /pets:
+    get:
+      parameters:
+        - name: filter
+          in: query
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  Name:
+                    type: string
+      responses:
+        200:
+          description: ok
+`))
+}
+
+func TestParseOperationViews(t *testing.T) {
+	doc := `
+openapi: 3.0.3
+paths:
+  /payments:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Payment'
+      responses:
+        201:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Payment'
+components:
+  schemas:
+    Payment:
+      type: object
+      properties:
+        Id:
+          type: string
+          readOnly: true
+        Amount:
+          type: string
+`
+	properties := func(view View) [][]string {
+		operations, err := ParseOperations(strings.NewReader(doc), Options{View: view})
+		require.NoError(t, err)
+		var names [][]string
+		for _, op := range operations {
+			var list []string
+			for _, p := range op.Diagram.Root.Properties {
+				list = append(list, p.Name)
+			}
+			names = append(names, list)
+		}
+		return names
+	}
+
+	assert.Equal(t, [][]string{{"Amount"}, {"Amount", "Id"}}, properties(ViewAuto))
+	assert.Equal(t, [][]string{{"Amount", "Id"}, {"Amount", "Id"}}, properties(ViewAll))
+	assert.Equal(t, [][]string{{"Amount"}, {"Amount"}}, properties(ViewRequest))
+}
@@ -3,6 +3,11 @@ package js2svg
 import (
 	"fmt"
 	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 )
@@ -13,51 +18,229 @@ var (
 	internalDivider = "."
 )
 
+// refKey is added to every schema which was inlined in place of a $ref, holding
+// the original reference. It lets the parser name and identify shared definitions.
+const refKey = "x-js2svg-ref"
+
+// mappingKey is added to the schemas with a discriminator mapping, holding the
+// schemas of the subtypes by the values of the discriminator property
+const mappingKey = "x-js2svg-mapping"
+
+// inheritedKey is added to the schemas of subtypes rendered without their base,
+// holding the properties of the base. The required fields of a subtype are
+// looked up among them as well.
+const inheritedKey = "x-js2svg-inherited"
+
+// Options control how a document is turned into a Diagram. The zero value
+// gives the default behaviour.
+type Options struct {
+	// SeparateAllOf renders the referenced members of an allOf as separate base
+	// boxes connected with an inheritance edge, instead of merging them into the object.
+	SeparateAllOf bool
+
+	// SharedDefinitions renders each referenced definition as a single object,
+	// connected to every object using it, instead of repeating it for each use.
+	SharedDefinitions bool
+	// EnumBoxes renders the values allowed in enumerated properties as separate
+	// «enumeration» boxes connected to the object of the property.
+	EnumBoxes bool
+	// InlineTypes renders the properties in UML style, "name: type [cardinality]",
+	// instead of showing the types in tooltips only
+	InlineTypes bool
+	// View selects the properties of the schemas shared by requests and responses:
+	// ViewRequest omits the readOnly ones, ViewResponse the writeOnly ones. ViewAll
+	// renders all of them, marked with their access. By default the bodies of
+	// operations are rendered in the view of their direction.
+	View View
+	// HideDeprecated leaves out the deprecated properties, objects and alternatives,
+	// which are rendered struck through by default.
+	HideDeprecated bool
+
+	// Strict fails on any malformed or unsupported node of the schema. By default
+	// the parser is lenient: it infers 'type: object' from the properties, skips
+	// the malformed nodes and records a warning in the Diagnostics of the Diagram.
+	Strict bool
+
+	// BaseURI is the location of the source document (a file path or an uri).
+	// Relative external references are resolved from here.
+	BaseURI string
+	// Loader fetches the documents of external references. DefaultLoader is used if nil.
+	Loader Loader
+}
+
+// View of the schemas shared by requests and responses
+type View int
+
+const (
+	// ViewAuto renders the request bodies of operations in the request view and
+	// the responses in the response view, any other schema as ViewAll
+	ViewAuto View = iota
+	// ViewAll renders the readOnly and writeOnly properties, marked as such
+	ViewAll
+	// ViewRequest renders the properties sent in requests, without the readOnly ones
+	ViewRequest
+	// ViewResponse renders the properties received in responses, without the writeOnly ones
+	ViewResponse
+)
+
+// ParseView returns the view named "auto", "all", "request" or "response" ("" is "auto")
+func ParseView(name string) (View, error) {
+	switch name {
+	case "", "auto":
+		return ViewAuto, nil
+	case "all":
+		return ViewAll, nil
+	case "request":
+		return ViewRequest, nil
+	case "response":
+		return ViewResponse, nil
+	}
+	return ViewAuto, fmt.Errorf("unknown view '%s', expected auto, all, request or response", name)
+}
+
+func (v View) String() string {
+	switch v {
+	case ViewRequest:
+		return "request"
+	case ViewResponse:
+		return "response"
+	case ViewAll:
+		return "all"
+	}
+	return "auto"
+}
+
 // ParseToDiagram performs all the necessary steps for creating a diagram in one function.
 func ParseToDiagram(src io.Reader, objectPath string) (*Diagram, error) {
-	objectPath = strings.ReplaceAll(objectPath, ExternalDivider, internalDivider)
-	m, err := ParseToMap(src, objectPath)
+	return ParseToDiagramWithOptions(src, objectPath, Options{})
+}
+
+// ParseToDiagramWithOptions is ParseToDiagram with non-default options.
+func ParseToDiagramWithOptions(src io.Reader, objectPath string, opts Options) (*Diagram, error) {
+	m, tokens, err := parseToMap(src, objectPath, opts)
 	if err != nil {
 		return nil, err
 	}
-	return MakeDiagram(m, objectPath)
+	if len(tokens) == 0 {
+		return MakeDiagramWithOptions(m, "", opts)
+	}
+	return MakeDiagramWithOptions(m, formatPointer(tokens), opts)
 }
 
 // MakeDiagram from a document unmarshalled to a map (useful when multiple diagrams are rendered from the same document)
 func MakeDiagram(m map[string]interface{}, path string) (*Diagram, error) {
-	psegs := strings.Split(path, ExternalDivider)
-	root := &Object{Name: psegs[len(psegs)-1]}
-	err := parseProperties(m, root)
+	return MakeDiagramWithOptions(m, path, Options{})
+}
+
+// MakeDiagramWithOptions is MakeDiagram with non-default options.
+func MakeDiagramWithOptions(m map[string]interface{}, path string, opts Options) (*Diagram, error) {
+	root := &Object{Name: path}
+	tokens, err := pathTokens(internalPath(path))
+	if err == nil && len(tokens) > 0 {
+		root.Name = tokens[len(tokens)-1]
+	}
+	p := &parser{
+		opts:      opts,
+		root:      root,
+		ancestors: map[string]*Object{},
+		shared:    map[string]*Object{},
+		enums:     map[string]*Object{},
+		location:  "#" + formatPointer(tokens),
+	}
+	defer p.enter(m)()
+
+	err = p.parseProperties(m, root)
 	if err != nil {
 		return nil, err
 	}
+	for _, d := range p.diagnostics {
+		if d.Severity == Error {
+			return nil, &d
+		}
+	}
+
+	return &Diagram{Root: root, InlineTypes: opts.InlineTypes, Diagnostics: p.diagnostics}, nil
+}
+
+// internalPath replaces the ExternalDivider in dotted paths. JSON Pointers are
+// returned as they are.
+func internalPath(path string) string {
+	if strings.HasPrefix(path, "/") || strings.HasPrefix(path, "#") {
+		return path
+	}
+	return strings.ReplaceAll(path, ExternalDivider, internalDivider)
+}
 
-	return &Diagram{Root: root}, nil
+// parser holds the state shared while walking a single schema
+type parser struct {
+	opts      Options
+	root      *Object            // the object of the selected schema
+	ancestors map[string]*Object // $ref -> object on the current branch
+	shared    map[string]*Object // $ref -> object of the definition, with SharedDefinitions
+	enums     map[string]*Object // $ref or values -> «enumeration» box, with EnumBoxes
+
+	location    string // pointer of the schema being parsed
+	diagnostics []Diagnostic
 }
 
 // ParseToMap the selected objectPath. If ObjectPath is the root element of the jsonschema document
 // then all references are going to be resolved in the returned map. This map can be resued with MakeDiagram
 // to generate multiple diagrams from the same source without unmarshallig / resolving references each time.
 func ParseToMap(src io.Reader, objectPath string) (map[string]interface{}, error) {
-	objectPath = strings.ReplaceAll(objectPath, ExternalDivider, internalDivider)
+	return ParseToMapWithOptions(src, objectPath, Options{})
+}
+
+// ParseToMapWithOptions is ParseToMap with non-default options. Set BaseURI
+// to resolve references to other files relative to the source.
+// The objectPath may also be the bare name of a schema, which is looked up in the
+// definitions of Swagger 2.0, the components.schemas of OpenAPI 3 or the $defs
+// of JSON Schema documents.
+func ParseToMapWithOptions(src io.Reader, objectPath string, opts Options) (map[string]interface{}, error) {
+	m, _, err := parseToMap(src, objectPath, opts)
+	return m, err
+}
+
+// parseToMap is ParseToMapWithOptions also returning the location of the object
+func parseToMap(src io.Reader, objectPath string, opts Options) (map[string]interface{}, []string, error) {
+	objectPath = internalPath(objectPath)
 	c, err := unmarshalSrc(src)
 	if err != nil {
-		return nil, err
+		return nil, nil, errorAt("#", "%v", err)
+	}
+
+	uri := opts.BaseURI
+	if u, err := url.Parse(uri); err == nil && uri != "" && u.Scheme == "" {
+		if uri, err = filepath.Abs(uri); err != nil {
+			return nil, nil, err
+		}
+		uri = filepath.ToSlash(uri)
+	}
+
+	tokens, err := pathTokens(objectPath)
+	if err != nil {
+		return nil, nil, errorAt(objectPath, "invalid path: %v", err)
+	}
+	if _, found := getTokens(c, tokens); !found {
+		named, found := schemaTokens(c, objectPath)
+		if !found || !isBareName(objectPath) {
+			return nil, nil, errorAt(refID(uri, tokens), "object not found")
+		}
+		tokens = named
 	}
 
 	// resolve $ref items and replace them with the actual definitions
-	resolved, err := resolveReferences(c, objectPath)
+	resolved, err := newResolver(c, uri, opts.Loader).resolve(uri, tokens)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// expect the top level item to be an object (vs. array or scalar)
 	var ok bool
 	c, ok = resolved.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("src is not an object")
+		return nil, nil, errorAt(refID(uri, tokens), "src is not an object")
 	}
-	return c, nil
+	return c, tokens, nil
 
 }
 
@@ -65,8 +248,9 @@ func ParseToMap(src io.Reader, objectPath string) (map[string]interface{}, error
 // Iterable can provide the same values as slice members sorted alphabetically
 type iterable []iterItem
 type iterItem struct {
-	Key   string
-	Value interface{}
+	Key     string
+	Value   interface{}
+	Pointer []string // location of the value relative to its parent, if not a property
 }
 
 func (it iterable) Len() int           { return len(it) }
@@ -92,86 +276,397 @@ func mapToIter(m map[string]interface{}) iterable {
 }
 
 // expected to pass a root object with its name & description populated outside this func
-func parseProperties(m map[string]interface{}, parent *Object) error {
-	typ, ok := m["type"].(string)
-	if !ok {
+func (p *parser) parseProperties(m map[string]interface{}, parent *Object) error {
+	m, bases := p.mergeAllOf(m)
+	if err := p.composeBases(parent, bases); err != nil {
+		return err
+	}
+	return p.parseObject(m, parent)
+}
+
+// parseObject is parseProperties for a schema which has no allOf left to merge
+func (p *parser) parseObject(m map[string]interface{}, parent *Object) error {
+	if ref, ok := m[refKey].(string); ok && p.ancestors[ref] == nil {
+		p.ancestors[ref] = parent
+		defer delete(p.ancestors, ref)
+		if p.opts.SharedDefinitions && p.shared[ref] == nil {
+			p.shared[ref] = parent
+		}
+	}
+
+	if err := p.composeVariants(m, parent); err != nil {
+		return err
+	}
+	if err := p.composeSubtypes(m, parent); err != nil {
+		return err
+	}
+
+	typ := schemaType(m)
+	if typ == "" {
 		var fields []string
-		for k := range m {
-			fields = append(fields, k)
+		for _, k := range mapToIter(m) {
+			fields = append(fields, k.Key)
 		}
-		return fmt.Errorf("parsing error: expecting an object with 'type' field: %v", fields)
+		p.report("expecting an object with 'type' field: %v", fields)
+		return nil
 	}
 
 	if typ != "object" {
+		// nested schemas of other types are properties of their parents already
+		if parent == p.root {
+			p.report("schema of type '%s' has no properties to render", typ)
+		}
 		return nil
-		// return fmt.Errorf("parsing error: container must be of 'object' type (got '%s')", typ)
 	}
 
-	properties := GetObject(m, "properties")
-	if properties == nil {
-		fmt.Println("not an object")
+	if err := p.parseFields(m, mapToIter(GetObject(m, "properties")), parent); err != nil {
+		return err
+	}
+	if err := p.parseMapEntries(m, parent); err != nil {
+		return err
+	}
+	p.checkRequired(m, parent)
+	return p.composeConditions(m, parent)
+}
+
+// parseFields adds the fields to the parent object. m is the schema of the parent,
+// used for looking up required fields.
+func (p *parser) parseFields(m map[string]interface{}, fields iterable, parent *Object) error {
+	for _, prop := range fields {
+		if err := p.parseField(m, prop, parent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseField adds a single field to the parent object
+func (p *parser) parseField(m map[string]interface{}, prop iterItem, parent *Object) error {
+	tokens := prop.Pointer
+	if tokens == nil {
+		tokens = []string{"properties", prop.Key}
+	}
+	defer p.enter(prop.Value, tokens...)()
+
+	pm, ok := prop.Value.(map[string]interface{})
+	if !ok {
+		p.report("the schema of '%s' is not an object", prop.Key)
 		return nil
 	}
-	for _, prop := range mapToIter(properties) {
-		rel := "0..1"
 
-		cm := prop.Value.(map[string]interface{})
-		switch cm["type"] {
-		case "object":
-			if isRequiredField(m, prop.Key) {
-				rel = "1..1"
-			}
-			child := &Object{}
-			child.Name = prop.Key
-			if desc, ok := cm["description"].(string); ok && len(desc) > 0 {
-				child.Description = desc
-			}
-			composeObject(parent, child, rel)
-			err := parseProperties(cm, child)
-			if err != nil {
-				return err
-			}
+	required := isRequiredField(m, prop.Key, pm)
+	cm, bases := p.mergeAllOf(pm)
+	if p.hidden(cm) {
+		return nil
+	}
+	if access := schemaAccess(cm); access != "" {
+		defer markAccess(parent, len(parent.Properties), len(parent.ComposedOf), access)
+	}
+	if ref, ok := cm["$ref"].(string); ok {
+		p.composeBackReference(parent, prop.Key, ref, cardinality(required, false))
+		return nil
+	}
 
-		case "array":
-			if isRequiredField(m, prop.Key) {
-				rel = "1..*"
-			} else {
-				rel = "0..*"
-			}
+	_, nullable := schemaTypes(cm)
+	required = required && !nullable
+	switch schemaType(cm) {
+	case "object":
+		child, exists := p.newObject(prop.Key, cm)
+		p.compose(parent, prop.Key, child, cardinality(required, false))
+		if exists {
+			return nil
+		}
+		if err := p.composeBases(child, bases); err != nil {
+			return err
+		}
+		return p.parseObject(cm, child)
 
-			child := &Object{}
-			child.Name = prop.Key
-			cm = GetObject(cm, "items")
-			if desc, ok := cm["description"].(string); ok && len(desc) > 0 {
-				child.Description = desc
+	case "array":
+		if isTuple(cm) {
+			child, exists := p.newObject(prop.Key, cm)
+			p.compose(parent, prop.Key, child, cardinality(required, false))
+			if exists {
+				return nil
 			}
-			switch cm["type"].(string) {
-			case "array", "object":
-				composeObject(parent, child, rel)
-				// new object within array
-				err := parseProperties(cm, child)
-				if err != nil {
-					return err
-				}
-				continue
-			default:
-				// properties := GetObject(cm, "properties")
+			return p.parseTuple(cm, child)
+		}
 
-				setScalarProperty(prop.Key, rel, properties, parent)
+		cm, bases, rel, itemTokens := p.arrayItems(cm, required)
+		defer p.enter(cm, itemTokens...)()
+		if ref, ok := cm["$ref"].(string); ok {
+			p.composeBackReference(parent, prop.Key, ref, rel)
+			return nil
+		}
+		switch {
+		case isTuple(cm):
+			child, exists := p.newObject(prop.Key, cm)
+			p.compose(parent, prop.Key, child, rel)
+			if exists {
+				return nil
 			}
+			return p.parseTuple(cm, child)
 
-		default: // scalar
-			if isRequiredField(m, prop.Key) {
-				rel = "1..1"
-			} else {
-				rel = "0..1"
+		case schemaType(cm) == "object":
+			child, exists := p.newObject(prop.Key, cm)
+			p.compose(parent, prop.Key, child, rel)
+			if exists {
+				return nil
 			}
+			if err := p.composeBases(child, bases); err != nil {
+				return err
+			}
+			// new object within array
+			return p.parseObject(cm, child)
+
+		default:
 			setScalarProperty(prop.Key, rel, cm, parent)
+			p.composeEnumeration(parent, prop.Key, cm)
 		}
+
+	default: // scalar
+		setScalarProperty(prop.Key, cardinality(required, false), cm, parent)
+		p.composeEnumeration(parent, prop.Key, cm)
 	}
 	return nil
 }
 
+// schemaAccess is "readOnly" or "writeOnly" if the schema is present in the
+// responses or the requests only
+func schemaAccess(m map[string]interface{}) string {
+	if readOnly, _ := m["readOnly"].(bool); readOnly {
+		return "readOnly"
+	}
+	if writeOnly, _ := m["writeOnly"].(bool); writeOnly {
+		return "writeOnly"
+	}
+	return ""
+}
+
+// hidden reports whether the schema is left out of the diagram: it's not part
+// of the view, or it's deprecated and deprecated elements are hidden
+func (p *parser) hidden(m map[string]interface{}) bool {
+	if deprecated, _ := m["deprecated"].(bool); deprecated && p.opts.HideDeprecated {
+		return true
+	}
+	access := schemaAccess(m)
+	return p.opts.View == ViewRequest && access == "readOnly" ||
+		p.opts.View == ViewResponse && access == "writeOnly"
+}
+
+// markAccess sets the access of the properties and compositions added to the
+// parent since it had the given number of them
+func markAccess(parent *Object, properties, compositions int, access string) {
+	for i := properties; i < len(parent.Properties); i++ {
+		parent.Properties[i].ReadOnly = access == "readOnly"
+		parent.Properties[i].WriteOnly = access == "writeOnly"
+	}
+	for i := compositions; i < len(parent.ComposedOf); i++ {
+		parent.ComposedOf[i].Access = access
+	}
+}
+
+// arrayItems returns the schema of the items of an array. The items of nested
+// arrays are followed to the innermost schema, the cardinality describes all the
+// dimensions, eg. "1..3 of 0..*". The tokens locate the items within the array.
+func (p *parser) arrayItems(m map[string]interface{}, required bool) (map[string]interface{}, []map[string]interface{}, string, []string) {
+	rels := []string{boundedCardinality(m, "minItems", "maxItems", required)}
+	tokens := []string{"items"}
+	items, bases := p.mergeAllOf(GetObject(m, "items"))
+	for schemaType(items) == "array" && !isTuple(items) && items["$ref"] == nil {
+		// the inner arrays are items, always present
+		rels = append(rels, boundedCardinality(items, "minItems", "maxItems", true))
+		tokens = append(tokens, "items")
+		items, bases = p.mergeAllOf(GetObject(items, "items"))
+	}
+	return items, bases, strings.Join(rels, " of "), tokens
+}
+
+// isTuple reports whether the schema describes a tuple: an array with positional
+// items (prefixItems, or items given as an array of schemas)
+func isTuple(m map[string]interface{}) bool {
+	_, positional := m["items"].([]interface{})
+	return m["prefixItems"] != nil || positional
+}
+
+// parseTuple adds the positions of a tuple to the object as indexed fields
+// ("[0]", "[1]" ...). Positions within minItems are required, the items allowed
+// after the positional ones are added as "[n..]".
+func (p *parser) parseTuple(m map[string]interface{}, o *Object) error {
+	positionsKey, restKey := "prefixItems", "items"
+	positions, ok := m[positionsKey].([]interface{})
+	if !ok {
+		positionsKey, restKey = "items", "additionalItems"
+		positions, _ = m[positionsKey].([]interface{})
+	}
+	rest := m[restKey]
+
+	var minItems float64
+	if n := numberField(m, "minItems"); n != nil {
+		minItems = *n
+	}
+	var required []interface{}
+	var fields iterable
+	for i, position := range positions {
+		if _, ok := position.(map[string]interface{}); !ok {
+			p.reportAt([]string{positionsKey, fmt.Sprint(i)}, "the schema of position %d is not an object", i)
+			continue
+		}
+		key := fmt.Sprintf("[%d]", i)
+		fields = append(fields, iterItem{Key: key, Value: position, Pointer: []string{positionsKey, fmt.Sprint(i)}})
+		if float64(i) < minItems {
+			required = append(required, key)
+		}
+	}
+
+	switch t := rest.(type) {
+	case map[string]interface{}:
+		fields = append(fields, iterItem{
+			Key:     fmt.Sprintf("[%d..]", len(positions)),
+			Value:   map[string]interface{}{"type": "array", "items": t},
+			Pointer: []string{}, // the tuple itself, its items are the rest
+		})
+	case bool:
+		if t {
+			fields = append(fields, iterItem{
+				Key:     fmt.Sprintf("[%d..]", len(positions)),
+				Value:   map[string]interface{}{"type": "array", "items": map[string]interface{}{}},
+				Pointer: []string{},
+			})
+		}
+	}
+
+	return p.parseFields(map[string]interface{}{"required": required}, fields, o)
+}
+
+// parseMapEntries adds the entries of a dictionary-like schema (additionalProperties
+// or patternProperties) to the object. Object values are composed as map
+// compositions qualified with the key, scalar values are added as properties.
+func (p *parser) parseMapEntries(m map[string]interface{}, parent *Object) error {
+	type entry struct {
+		key    string
+		schema map[string]interface{}
+		tokens []string
+	}
+
+	var entries []entry
+	for _, pattern := range mapToIter(GetObject(m, "patternProperties")) {
+		tokens := []string{"patternProperties", pattern.Key}
+		if vs, ok := pattern.Value.(map[string]interface{}); ok {
+			entries = append(entries, entry{fmt.Sprintf("[key: /%s/]", pattern.Key), vs, tokens})
+		} else {
+			p.reportAt(tokens, "the schema of the pattern is not an object")
+		}
+	}
+
+	keyType := "string"
+	if names := GetObject(m, "propertyNames"); names["pattern"] != nil {
+		keyType = fmt.Sprintf("/%v/", names["pattern"])
+	}
+	tokens := []string{"additionalProperties"}
+	switch t := m["additionalProperties"].(type) {
+	case map[string]interface{}:
+		entries = append(entries, entry{fmt.Sprintf("[key: %s]", keyType), t, tokens})
+	case bool:
+		if t {
+			entries = append(entries, entry{fmt.Sprintf("[key: %s]", keyType), map[string]interface{}{}, tokens})
+		}
+	case nil:
+	default:
+		p.reportAt(tokens, "additionalProperties must be a schema or a boolean")
+	}
+
+	// the number of entries is bounded by the number of properties if there are no others
+	rel := "0..*"
+	if len(entries) == 1 && len(GetObject(m, "properties")) == 0 {
+		rel = boundedCardinality(m, "minProperties", "maxProperties", true)
+	}
+	for _, e := range entries {
+		if err := p.parseMapEntry(parent, e.key, e.schema, e.tokens, rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseMapEntry adds an entry of a dictionary-like schema with the key described by key
+func (p *parser) parseMapEntry(parent *Object, key string, schema map[string]interface{}, tokens []string, rel string) error {
+	defer p.enter(schema, tokens...)()
+	vs, bases := p.mergeAllOf(schema)
+	if ref, ok := vs["$ref"].(string); ok {
+		p.composeBackReference(parent, key, ref, rel)
+		parent.ComposedOf[len(parent.ComposedOf)-1].Kind = Map
+		return nil
+	}
+
+	typ := schemaType(vs)
+	items := vs
+	if typ == "array" {
+		items = GetObject(vs, "items")
+	}
+
+	if typ == "object" || (typ == "array" && schemaType(items) == "object") {
+		child, exists := p.newObject(parent.Name+"Value", items)
+		composeObject(parent, child, rel)
+		parent.ComposedOf[len(parent.ComposedOf)-1].Name = key
+		parent.ComposedOf[len(parent.ComposedOf)-1].Kind = Map
+		if exists {
+			return nil
+		}
+		if err := p.composeBases(child, bases); err != nil {
+			return err
+		}
+		return p.parseObject(items, child)
+	}
+
+	setScalarProperty(key, rel, vs, parent)
+	valueType := typeLabel(items)
+	if valueType == "" {
+		valueType = "any"
+	}
+	if typ == "array" {
+		valueType += "[]"
+	}
+	parent.Properties[len(parent.Properties)-1].Type = valueType
+	return nil
+}
+
+// cardinality of a property. Nullable properties are never required.
+func cardinality(required, many bool) string {
+	switch {
+	case required && many:
+		return "1..*"
+	case many:
+		return "0..*"
+	case required:
+		return "1..1"
+	default:
+		return "0..1"
+	}
+}
+
+// boundedCardinality is the cardinality of a collection with the bounds of its
+// schema at the keys, eg. "1..3" for minItems 1 and maxItems 3. A collection which
+// may be absent has no lower bound, one which is present has the minimum if any:
+// a required array may still be empty.
+func boundedCardinality(m map[string]interface{}, minKey, maxKey string, present bool) string {
+	var lower float64
+	if min := numberField(m, minKey); min != nil && *min >= 0 {
+		lower = *min
+	}
+	if !present {
+		lower = 0
+	}
+
+	upper := "*"
+	if max := numberField(m, maxKey); max != nil && *max >= 0 {
+		upper = fmt.Sprint(*max)
+		if lower > *max {
+			lower = *max
+		}
+	}
+	return fmt.Sprintf("%v..%s", lower, upper)
+}
+
 func setArrayProperties(itemsSchema map[string]interface{}) {
 	// WIP: refactor parseProperties
 }
@@ -195,52 +690,142 @@ func setObjectProperties(objectSchema map[string]interface{}, object *Object) er
 }
 
 func setScalarProperty(propertyName, rel string, propertySchema map[string]interface{}, o *Object) {
-	info := strings.Builder{}
-	for _, key := range []string{"type", "format", "minLength", "maxLength", "description", "enum", "x-namespaced-enum", "pattern"} {
-		switch key {
-		case "enum", "x-namespaced-enum":
-			if value := propertySchema[key]; value != nil {
-				info.WriteString("Values:\n")
-				for _, v := range value.([]interface{}) {
-					info.WriteString(fmt.Sprintf(" - %s\n", v))
-				}
-			}
-		default:
-			if propertySchema[key] != nil {
-				value := fmt.Sprint(propertySchema[key])
-				if len(value) > 0 {
-					info.WriteString(fmt.Sprintf("%s: %s\n", strings.Title(key), value))
-				}
-			}
-		}
-	}
-
 	newProperty := Property{
 		Name:         propertyName,
 		Relationship: rel,
+		Type:         typeLabel(propertySchema),
+		Default:      propertySchema["default"],
+		Example:      propertySchema["example"],
 	}
 
-	if desc := info.String(); len(desc) > 0 {
-		newProperty.Description = desc
+	newProperty.Description, _ = propertySchema["description"].(string)
+	newProperty.Format, _ = propertySchema["format"].(string)
+	newProperty.Pattern, _ = propertySchema["pattern"].(string)
+	newProperty.Deprecated, _ = propertySchema["deprecated"].(bool)
+	newProperty.ReadOnly, _ = propertySchema["readOnly"].(bool)
+	newProperty.WriteOnly, _ = propertySchema["writeOnly"].(bool)
+	newProperty.MinLength = numberField(propertySchema, "minLength")
+	newProperty.MaxLength = numberField(propertySchema, "maxLength")
+	newProperty.Minimum = numberField(propertySchema, "minimum")
+	newProperty.Maximum = numberField(propertySchema, "maximum")
+
+	newProperty.Enum = enumValues(propertySchema)
+
+	if examples, ok := propertySchema["examples"].([]interface{}); ok && newProperty.Example == nil && len(examples) > 0 {
+		newProperty.Example = examples[0]
 	}
 
 	o.Properties = append(o.Properties, newProperty)
 }
 
-// m is the map with the unmarshalled root schema of the object with the property
-// containing the "required" field. name is the name of the field.
-func isRequiredField(m map[string]interface{}, name string) bool {
-	req, _ := m["required"].([]interface{})
-	if req == nil {
-		return false
+// enumValues are the values allowed by the enum and x-namespaced-enum keywords
+func enumValues(m map[string]interface{}) []string {
+	var enum []string
+	for _, key := range []string{"enum", "x-namespaced-enum"} {
+		values, _ := m[key].([]interface{})
+		for _, v := range values {
+			enum = append(enum, fmt.Sprint(v))
+		}
+	}
+	return enum
+}
+
+// composeEnumeration connects the object with an «enumeration» box listing the
+// values allowed in the property (with EnumBoxes). The same enumeration is rendered
+// once, identified by the definition it comes from or by its values.
+func (p *parser) composeEnumeration(o *Object, name string, m map[string]interface{}) {
+	values := enumValues(m)
+	if !p.opts.EnumBoxes || len(values) == 0 {
+		return
+	}
+
+	key, enumName := strings.Join(values, "\n"), name
+	if ref, ok := m[refKey].(string); ok {
+		key, enumName = ref, refName(ref)
+	}
+
+	enum := p.enums[key]
+	if enum == nil {
+		enum = &Object{Name: enumName, Values: values}
+		enum.Description, _ = m["description"].(string)
+		p.enums[key] = enum
+	}
+
+	c := Composition{Kind: Enumeration, Object: enum}
+	if enum.Name != name {
+		c.Name = name
+	}
+	o.ComposedOf = append(o.ComposedOf, c)
+}
+
+// numberField returns the numeric value of the keyword, or nil if the schema doesn't have it
+func numberField(m map[string]interface{}, key string) *float64 {
+	var n float64
+	switch t := m[key].(type) {
+	case int:
+		n = float64(t)
+	case int64:
+		n = float64(t)
+	case uint64:
+		n = float64(t)
+	case float64:
+		n = t
+	default:
+		return nil
 	}
+	return &n
+}
 
-	for _, v := range req {
-		if strings.ToLower(v.(string)) == strings.ToLower(name) {
+// isRequiredField reports whether the field is required by m, the schema of the
+// object: listed in its required (or x-required) names, matched exactly. The
+// field schema may also say it's required with 'required: true', the way of
+// draft-03 and Swagger 2.0 parameters, or the 'x-required' extension.
+func isRequiredField(m map[string]interface{}, name string, field map[string]interface{}) bool {
+	for _, key := range []string{"required", "x-required"} {
+		if required, _ := field[key].(bool); required {
 			return true
 		}
+		for _, v := range GetSlice(m, key) {
+			if s, ok := v.(string); ok && s == name {
+				return true
+			}
+		}
 	}
+	return false
+}
 
+// checkRequired warns about the names in the required list of m which are not
+// fields of the object o: neither its properties (including the hidden and
+// inherited ones) nor matched by its patternProperties.
+func (p *parser) checkRequired(m map[string]interface{}, o *Object) {
+	fields := map[string]bool{}
+	o.fieldNames(fields)
+	for _, key := range []string{"properties", inheritedKey} {
+		for name := range GetObject(m, key) {
+			fields[name] = true
+		}
+	}
+
+	for i, v := range GetSlice(m, "required") {
+		name, ok := v.(string)
+		if !ok {
+			p.reportAt([]string{"required", fmt.Sprint(i)}, "required name is not a string: %v", v)
+			continue
+		}
+		if !fields[name] && !matchesPattern(GetObject(m, "patternProperties"), name) {
+			p.warn("required property '%s' is not defined", name)
+		}
+	}
+}
+
+// matchesPattern reports whether the name matches any of the patterns (the keys
+// of patternProperties). Invalid patterns match nothing.
+func matchesPattern(patterns map[string]interface{}, name string) bool {
+	for pattern := range patterns {
+		if re, err := regexp.Compile(pattern); err == nil && re.MatchString(name) {
+			return true
+		}
+	}
 	return false
 }
 
@@ -251,40 +836,688 @@ func composeObject(parent, child *Object, rel string) {
 	})
 }
 
+// newObject returns the object for the schema m of the property called name.
+// With SharedDefinitions there is a single object for each referenced definition,
+// named after the definition: exists reports whether it has already been parsed.
+func (p *parser) newObject(name string, m map[string]interface{}) (o *Object, exists bool) {
+	ref, ok := m[refKey].(string)
+	if p.opts.SharedDefinitions && ok {
+		if o := p.shared[ref]; o != nil {
+			return o, true
+		}
+		name = refName(ref)
+	}
+
+	o = &Object{Name: name}
+	if desc, ok := m["description"].(string); ok && len(desc) > 0 {
+		o.Description = desc
+	}
+	o.Deprecated, _ = m["deprecated"].(bool)
+	if p.opts.SharedDefinitions && ok {
+		p.shared[ref] = o
+	}
+	return o, false
+}
+
+// compose adds the child to the parent, recording the property name if the
+// object is named differently
+func (p *parser) compose(parent *Object, name string, child *Object, rel string) {
+	composeObject(parent, child, rel)
+	if child.Name != name {
+		parent.ComposedOf[len(parent.ComposedOf)-1].Name = name
+	}
+}
+
+// composeBackReference adds a stub object to the parent pointing back to the
+// object of a recursive reference. With SharedDefinitions the object itself is composed.
+func (p *parser) composeBackReference(parent *Object, name, ref, rel string) {
+	if target := p.shared[ref]; target != nil {
+		p.compose(parent, name, target, rel)
+		return
+	}
+
+	target := p.ancestors[ref]
+	if target == nil {
+		p.warn("recursive reference '%s' points outside of the diagram", ref)
+		target = &Object{Name: refName(ref)}
+	}
+	composeObject(parent, &Object{Name: name, BackReference: target}, rel)
+}
+
+// mergeAllOf returns a copy of m with the members of its allOf merged in:
+// properties and required lists are combined, and the type, description and
+// any other keywords missing from m are taken from the first member providing them.
+// With SeparateAllOf the referenced object members are not merged but returned
+// as bases, to be rendered as boxes of their own.
+func (p *parser) mergeAllOf(m map[string]interface{}) (map[string]interface{}, []map[string]interface{}) {
+	members, ok := m["allOf"].([]interface{})
+	if !ok {
+		return p.inferType(m), nil
+	}
+
+	merged := map[string]interface{}{}
+	for k, v := range m {
+		if k != "allOf" {
+			merged[k] = v
+		}
+	}
+
+	var bases []map[string]interface{}
+	for i, member := range members {
+		mm, ok := member.(map[string]interface{})
+		if !ok {
+			p.reportAt([]string{"allOf", fmt.Sprint(i)}, "allOf member is not a schema object")
+			continue
+		}
+		if mm["$ref"] != nil {
+			p.reportAt([]string{"allOf", fmt.Sprint(i)}, "recursive allOf member '%s' is ignored", mm["$ref"])
+			continue
+		}
+
+		if p.opts.SeparateAllOf && mm[refKey] != nil && isObjectSchema(mm) {
+			bases = append(bases, mm)
+			continue
+		}
+
+		mm, memberBases := p.mergeAllOf(mm)
+		bases = append(bases, memberBases...)
+		for k, v := range mm {
+			switch k {
+			case "properties":
+				props := GetObject(merged, "properties")
+				combined := make(map[string]interface{}, len(props))
+				for name, schema := range props {
+					combined[name] = schema
+				}
+				for name, schema := range GetObject(mm, "properties") {
+					if _, exists := combined[name]; !exists {
+						combined[name] = schema
+					}
+				}
+				merged["properties"] = combined
+
+			case "required":
+				required, _ := merged["required"].([]interface{})
+				combined := append([]interface{}{}, required...)
+				for j, v := range GetSlice(mm, "required") {
+					name, ok := v.(string)
+					if !ok {
+						p.reportAt([]string{"allOf", fmt.Sprint(i), "required", fmt.Sprint(j)}, "required name is not a string: %v", v)
+						continue
+					}
+					if !containsName(combined, name) {
+						combined = append(combined, name)
+					}
+				}
+				merged["required"] = combined
+
+			case refKey, mappingKey:
+				// the merged schema is not the referenced one, nor the base of the subtypes
+
+			default:
+				if _, exists := merged[k]; !exists {
+					merged[k] = v
+				}
+			}
+		}
+	}
+
+	if merged["type"] == nil && (len(bases) > 0 || merged["properties"] != nil) {
+		merged["type"] = "object"
+	}
+
+	return merged, bases
+}
+
+// inferType returns a copy of m with 'type: object' if m has properties but no type.
+// The type is not inferred in Strict mode.
+func (p *parser) inferType(m map[string]interface{}) map[string]interface{} {
+	if m["type"] != nil || isVariantSchema(m) {
+		return m
+	}
+	if _, ok := m["additionalProperties"].(map[string]interface{}); !ok && m["properties"] == nil && m["patternProperties"] == nil {
+		return m
+	}
+	if p.opts.Strict {
+		p.report("missing 'type: object' of a schema with properties")
+		return m
+	}
+
+	inferred := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		inferred[k] = v
+	}
+	inferred["type"] = "object"
+	return inferred
+}
+
+// composeBases adds the schemas separated from an allOf as base objects of o
+func (p *parser) composeBases(o *Object, bases []map[string]interface{}) error {
+	for _, b := range bases {
+		leave := p.enter(b)
+		base, exists := p.newObject(refName(b[refKey]), b)
+		o.ComposedOf = append(o.ComposedOf, Composition{
+			Kind:   Inheritance,
+			Object: base,
+		})
+		var err error
+		if !exists {
+			err = p.parseProperties(b, base)
+		}
+		leave()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// composeVariants turns o into a variant node when the schema is a oneOf or
+// anyOf union, composing each alternative as a child object
+func (p *parser) composeVariants(m map[string]interface{}, o *Object) error {
+	for _, v := range []struct {
+		key  string
+		kind CompositionKind
+	}{
+		{"oneOf", OneOf},
+		{"anyOf", AnyOf},
+	} {
+		alternatives, ok := m[v.key].([]interface{})
+		if !ok {
+			continue
+		}
+
+		o.Variant = v.key
+		for i, alt := range alternatives {
+			if err := p.composeVariant(o, v.kind, v.key, i, alt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// composeSubtypes connects the object with the subtypes of its discriminator
+// mapping. Alternatives of a union which are also mapped are labelled with the
+// discriminator value instead.
+func (p *parser) composeSubtypes(m map[string]interface{}, o *Object) error {
+	mapping, _ := m[mappingKey].(map[string]interface{})
+	property, _ := GetObject(m, "discriminator")["propertyName"].(string)
+	for _, entry := range mapToIter(mapping) {
+		if err := p.composeSubtype(o, m, property, entry.Key, entry.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// composeSubtype adds the subtype selected by the value of the discriminator
+// property to o, the object of the base schema m
+func (p *parser) composeSubtype(o *Object, m map[string]interface{}, property, value string, subtype interface{}) error {
+	base, _ := m[refKey].(string)
+	defer p.enter(subtype, "discriminator", "mapping", value)()
+	sm, ok := subtype.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if ref, ok := sm["$ref"].(string); ok {
+		if ref != base {
+			// a subtype which is also an ancestor of o
+			p.composeBackReference(o, refName(ref), ref, "")
+			c := &o.ComposedOf[len(o.ComposedOf)-1]
+			c.Kind, c.Name, c.Value = Subtype, property, value
+		}
+		return nil
+	}
+	if p.hidden(sm) {
+		return nil
+	}
+
+	name := alternativeName(sm, len(o.ComposedOf))
+	for i, c := range o.ComposedOf {
+		if (c.Kind == OneOf || c.Kind == AnyOf) && c.Object.Name == name {
+			o.ComposedOf[i].Name, o.ComposedOf[i].Value = property, value
+			return nil
+		}
+	}
+
+	child, exists := p.newObject(name, sm)
+	o.ComposedOf = append(o.ComposedOf, Composition{
+		Name:   property,
+		Value:  value,
+		Kind:   Subtype,
+		Object: child,
+	})
+	if exists {
+		return nil
+	}
+	return p.parseProperties(withoutBase(sm, base, GetObject(m, "properties")), child)
+}
+
+// withoutBase returns the schema of a subtype without the allOf member referring
+// back to the base, which is rendered by the subtype edge. The properties of the
+// base are kept at inheritedKey.
+func withoutBase(m map[string]interface{}, base string, inherited map[string]interface{}) map[string]interface{} {
+	members, ok := m["allOf"].([]interface{})
+	if !ok || base == "" {
+		return m
+	}
+
+	stripped := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		stripped[k] = v
+	}
+	var allOf []interface{}
+	for _, member := range members {
+		if mm, ok := member.(map[string]interface{}); !ok || mm["$ref"] != base {
+			allOf = append(allOf, member)
+		}
+	}
+	stripped["allOf"] = allOf
+	stripped[inheritedKey] = inherited
+	return stripped
+}
+
+// composeVariant adds the i-th alternative of the union at key to the variant object o
+func (p *parser) composeVariant(o *Object, kind CompositionKind, key string, i int, alt interface{}) error {
+	defer p.enter(alt, key, fmt.Sprint(i))()
+	am, ok := alt.(map[string]interface{})
+	if !ok {
+		p.report("%s alternative is not a schema object", key)
+		return nil
+	}
+	if p.hidden(am) {
+		return nil
+	}
+	if ref, ok := am["$ref"].(string); ok {
+		p.composeBackReference(o, refName(ref), ref, "")
+		o.ComposedOf[len(o.ComposedOf)-1].Kind = kind
+		return nil
+	}
+
+	// alternatives like {required: [...]} only constrain the parent: their box
+	// lists the properties they require, if any
+	if schemaType(am) == "" && !isObjectSchema(am) && !isVariantSchema(am) {
+		child := &Object{Name: alternativeName(am, i)}
+		child.Description, _ = am["description"].(string)
+		for _, v := range GetSlice(am, "required") {
+			if name, ok := v.(string); ok {
+				setScalarProperty(name, cardinality(true, false), map[string]interface{}{}, child)
+			}
+		}
+		if len(child.Properties) > 0 {
+			o.ComposedOf = append(o.ComposedOf, Composition{Kind: kind, Object: child})
+		}
+		return nil
+	}
+
+	child, exists := p.newObject(alternativeName(am, i), am)
+	o.ComposedOf = append(o.ComposedOf, Composition{
+		Kind:   kind,
+		Object: child,
+	})
+	if exists {
+		return nil
+	}
+
+	// scalar alternatives have no properties to render
+	if typ := schemaType(am); typ != "" && typ != "object" {
+		return nil
+	}
+	return p.parseProperties(am, child)
+}
+
+// alternativeName names the box of a oneOf / anyOf alternative
+func alternativeName(m map[string]interface{}, i int) string {
+	if ref := refName(m[refKey]); ref != "" {
+		return ref
+	}
+	if title, ok := m["title"].(string); ok && title != "" {
+		return title
+	}
+	if typ, ok := m["type"].(string); ok && typ != "object" {
+		return typ
+	}
+	return fmt.Sprintf("Option%d", i+1)
+}
+
+// schemaType is the type of the schema, where a oneOf / anyOf union without
+// a type is handled as an object (variant node). Of a union of types, objects
+// and arrays take precedence.
+func schemaType(m map[string]interface{}) string {
+	types, _ := schemaTypes(m)
+	for _, preferred := range []string{"object", "array"} {
+		for _, typ := range types {
+			if typ == preferred {
+				return typ
+			}
+		}
+	}
+	if len(types) > 0 {
+		return types[0]
+	}
+	if isVariantSchema(m) {
+		return "object"
+	}
+	return ""
+}
+
+// schemaTypes returns the types of the schema other than null, and whether it
+// is nullable. The type may be a string or an array of strings (JSON Schema),
+// and nullability may be given by the OpenAPI 3.0 nullable keyword.
+func schemaTypes(m map[string]interface{}) (types []string, nullable bool) {
+	nullable, _ = m["nullable"].(bool)
+
+	var all []interface{}
+	switch t := m["type"].(type) {
+	case string:
+		all = []interface{}{t}
+	case []interface{}:
+		all = t
+	}
+
+	for _, v := range all {
+		typ, ok := v.(string)
+		switch {
+		case !ok:
+		case typ == "null":
+			nullable = true
+		default:
+			types = append(types, typ)
+		}
+	}
+	return types, nullable
+}
+
+// typeLabel describes the type(s) of the schema, eg. "string | null"
+func typeLabel(m map[string]interface{}) string {
+	types, nullable := schemaTypes(m)
+	if nullable {
+		types = append(types, "null")
+	}
+	return strings.Join(types, " | ")
+}
+
+func isVariantSchema(m map[string]interface{}) bool {
+	return m["oneOf"] != nil || m["anyOf"] != nil
+}
+
+func isObjectSchema(m map[string]interface{}) bool {
+	return m["type"] == "object" || m["properties"] != nil || m["allOf"] != nil
+}
+
+// refName is the last segment of a reference, usually the name of the definition.
+// References to whole documents are named after the file.
+func refName(ref interface{}) string {
+	s, _ := ref.(string)
+	uri, fragment := s, ""
+	if i := strings.Index(s, "#"); i >= 0 {
+		uri, fragment = s[:i], s[i+1:]
+	}
+
+	if tokens, err := parsePointer(fragment); err == nil && len(tokens) > 0 {
+		return tokens[len(tokens)-1]
+	}
+	if uri == "" {
+		return ""
+	}
+	name := path.Base(uri)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// containsName reports whether the list of required names has the name. Other
+// values are ignored, they may not even be comparable.
+func containsName(values []interface{}, name string) bool {
+	for _, value := range values {
+		if s, ok := value.(string); ok && s == name {
+			return true
+		}
+	}
+	return false
+}
+
 func resolveReferences(c map[string]interface{}, path string) (interface{}, error) {
-	src := GetUnknown(c, path)
+	tokens, err := pathTokens(path)
+	if err != nil {
+		return nil, err
+	}
+	return newResolver(c, "", nil).resolve("", tokens)
+}
+
+// resolver inlines the $ref items of a document, loading the documents of external
+// references on demand. References pointing to a schema which is being resolved
+// (an ancestor of the $ref) are left in place as back-references, so recursive
+// schemas don't recurse forever.
+type resolver struct {
+	loader   Loader
+	docs     map[string]map[string]interface{} // document uri -> unmarshalled document
+	index    *index                            // $id and $anchor of the schemas in docs
+	visiting map[string]map[string]interface{} // reference -> copy of the schema under construction
+}
+
+func newResolver(doc map[string]interface{}, uri string, loader Loader) *resolver {
+	if loader == nil {
+		loader = DefaultLoader
+	}
+	r := &resolver{
+		loader:   loader,
+		docs:     map[string]map[string]interface{}{uri: doc},
+		index:    newIndex(),
+		visiting: map[string]map[string]interface{}{},
+	}
+	r.index.add(uri, doc)
+	return r
+}
+
+// resolve the value at the pointer made of tokens within the document identified by uri
+func (r *resolver) resolve(uri string, tokens []string) (interface{}, error) {
+	src, found := getTokens(r.docs[uri], tokens)
+	if !found {
+		return nil, errorAt(refID(uri, tokens), "object not found")
+	}
 	var err error
 
 	switch t := src.(type) {
 	case map[string]interface{}:
+		if ref, ok := t["$ref"].(string); ok {
+			return r.resolveRef(refID(uri, tokens), baseURI(uri, r.docs[uri], tokens), ref)
+		}
+
+		id := refID(uri, tokens)
 		dst := map[string]interface{}{}
-		for k, v := range t {
-			if k == "$ref" {
-				return resolveReferences(c, strings.ReplaceAll(v.(string)[2:], "/", "."))
-			}
-			subpath := strings.Join([]string{path, k}, ".")
-			dst[k], err = resolveReferences(c, subpath)
+		r.visiting[id] = dst
+		defer delete(r.visiting, id)
+
+		for k := range t {
+			dst[k], err = r.resolve(uri, appendToken(tokens, k))
 			if err != nil {
 				return nil, err
 			}
 		}
+		if err := r.resolveMapping(uri, tokens, t, dst); err != nil {
+			return nil, err
+		}
 		return dst, nil
 
 	case []interface{}:
 		dst := make([]interface{}, len(t))
 		for i := range t {
-			subpath := strings.Join([]string{path, fmt.Sprint(i)}, ".")
-			dst[i], err = resolveReferences(c, subpath)
+			dst[i], err = r.resolve(uri, appendToken(tokens, fmt.Sprint(i)))
 			if err != nil {
 				return nil, err
 			}
 		}
 		return dst, nil
 
-	case nil:
-		return nil, fmt.Errorf("reference '%s' not found", path)
-
 	default:
 		return t, nil
 	}
 }
+
+// resolveRef resolves a $ref found at the location given by from, relative
+// to the base uri in effect there
+func (r *resolver) resolveRef(from, base, ref string) (interface{}, error) {
+	targetURI, tokens, err := r.target(from, base, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	id := refID(targetURI, tokens)
+	if ancestor, ok := r.visiting[id]; ok {
+		// mark the ancestor so the parser can find the target of the back-reference
+		ancestor[refKey] = id
+		return map[string]interface{}{"$ref": id}, nil
+	}
+
+	resolved, err := r.resolve(targetURI, tokens)
+	if rm, ok := resolved.(map[string]interface{}); ok {
+		rm[refKey] = id
+	}
+	return resolved, err
+}
+
+// resolveMapping adds the schemas of the discriminator mapping of src to dst,
+// under mappingKey. The mapping values are references or schema names.
+func (r *resolver) resolveMapping(uri string, tokens []string, src, dst map[string]interface{}) error {
+	discriminator, _ := src["discriminator"].(map[string]interface{})
+	mapping, _ := discriminator["mapping"].(map[string]interface{})
+	if len(mapping) == 0 {
+		return nil
+	}
+
+	schemas := map[string]interface{}{}
+	for value, target := range mapping {
+		ref, ok := target.(string)
+		if !ok {
+			continue
+		}
+		if named, found := schemaTokens(r.docs[uri], ref); found && isBareName(ref) {
+			ref = "#" + formatPointer(named)
+		}
+
+		from := refID(uri, joinTokens(tokens, "discriminator", "mapping", value))
+		schema, err := r.resolveRef(from, baseURI(uri, r.docs[uri], tokens), ref)
+		if err != nil {
+			return err
+		}
+		schemas[value] = schema
+	}
+	dst[mappingKey] = schemas
+	return nil
+}
+
+// target follows the reference (and the chain of references if it points to
+// another $ref) to the document and the tokens of the definition. Identifiers
+// of the loaded documents are resolved from the index, other documents are loaded.
+func (r *resolver) target(from, base, ref string) (string, []string, error) {
+	seen := map[string]bool{}
+	for {
+		targetURI, fragment, err := resolveRefURI(base, ref)
+		if err != nil {
+			return "", nil, errorAt(from, "%v", err)
+		}
+
+		schema, indexed := r.index.ids[targetURI]
+		if !indexed {
+			if err := r.load(targetURI); err != nil {
+				return "", nil, errorAt(from, "%v", err)
+			}
+			schema = r.index.ids[targetURI]
+		}
+
+		var tokens []string
+		if fragment == "" || strings.HasPrefix(fragment, "/") {
+			pointer, err := parsePointer(fragment)
+			if err != nil {
+				return "", nil, errorAt(from, "invalid reference '%s': %v", ref, err)
+			}
+			tokens = append(append(tokens, schema.tokens...), pointer...)
+		} else {
+			anchor, found := r.index.anchors[targetURI+"#"+fragment]
+			if !found {
+				return "", nil, errorAt(from, "anchor of reference '%s' not found", ref)
+			}
+			schema, tokens = anchor, anchor.tokens
+		}
+
+		doc := r.docs[schema.uri]
+		target, found := getTokens(doc, tokens)
+		if !found {
+			// eg. #/definitions/Pet in an OpenAPI 3 document
+			if tokens, found = relocate(doc, tokens); !found {
+				return "", nil, errorAt(from, "reference '%s' not found", ref)
+			}
+			target, _ = getTokens(doc, tokens)
+		}
+
+		id := refID(schema.uri, tokens)
+		if seen[id] {
+			return "", nil, errorAt(from, "circular reference '%s'", ref)
+		}
+		seen[id] = true
+
+		tm, _ := target.(map[string]interface{})
+		next, ok := tm["$ref"].(string)
+		if !ok {
+			return schema.uri, tokens, nil
+		}
+		base, ref = baseURI(schema.uri, doc, tokens), next
+	}
+}
+
+func (r *resolver) load(uri string) error {
+	src, err := r.loader.Load(uri)
+	if err != nil {
+		return fmt.Errorf("loading '%s': %w", uri, err)
+	}
+	defer src.Close()
+
+	doc, err := unmarshalSrc(src)
+	if err != nil {
+		return fmt.Errorf("parsing '%s': %w", uri, err)
+	}
+	r.docs[uri] = doc
+	r.index.add(uri, doc)
+	return nil
+}
+
+// resolveRefURI returns the absolute uri of the document and the fragment
+// a reference points to. Relative references within a document without uri
+// are resolved from the working directory.
+func resolveRefURI(base, ref string) (string, string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid reference '%s': %w", ref, err)
+	}
+
+	if base == "" && u.Scheme == "" && u.Path != "" && !path.IsAbs(u.Path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", "", err
+		}
+		base = filepath.ToSlash(wd) + "/"
+	}
+
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid base uri '%s': %w", base, err)
+	}
+
+	target := b.ResolveReference(u)
+	fragment := target.Fragment
+	target.Fragment = ""
+	target.RawFragment = ""
+	return target.String(), fragment, nil
+}
+
+// refID identifies the value at the pointer made of tokens in the document with
+// the given uri, in the same format as the references the resolver leaves in the
+// resolved documents
+func refID(uri string, tokens []string) string {
+	return uri + "#" + formatPointer(tokens)
+}
+
+// appendToken returns a new slice, leaving the tokens of the parent untouched
+func appendToken(tokens []string, token string) []string {
+	return append(tokens[:len(tokens):len(tokens)], token)
+}
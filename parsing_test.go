@@ -0,0 +1,1134 @@
+package js2svg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const allOfDoc = `
+components:
+  schemas:
+    Amount:
+      type: object
+      description: An amount of money
+      required: [Amount]
+      properties:
+        Amount:
+          type: string
+        Currency:
+          type: string
+    Charge:
+      description: A charge
+      allOf:
+        - $ref: '#/components/schemas/Amount'
+        - type: object
+          required: [Type]
+          properties:
+            Type:
+              type: string
+`
+
+func TestParseAllOfMerged(t *testing.T) {
+	d, err := ParseToDiagram(strings.NewReader(allOfDoc), "components.schemas.Charge")
+	require.NoError(t, err)
+
+	assert.Empty(t, d.Root.ComposedOf)
+	assert.Equal(t, []Property{
+		{Name: "Amount", Relationship: "1..1", Type: "string"},
+		{Name: "Currency", Relationship: "0..1", Type: "string"},
+		{Name: "Type", Relationship: "1..1", Type: "string"},
+	}, d.Root.Properties)
+}
+
+func TestParseAllOfRequiredNames(t *testing.T) {
+	doc := `
+type: object
+required: [{}]
+properties:
+  a:
+    type: string
+allOf:
+  - required: [{a: 1}, a]
+`
+	d, err := ParseToDiagram(strings.NewReader(doc), "")
+	require.NoError(t, err)
+	require.Len(t, d.Root.Properties, 1)
+	assert.Equal(t, "1..1", d.Root.Properties[0].Relationship)
+
+	var pointers []string
+	for _, diag := range d.Diagnostics {
+		pointers = append(pointers, diag.Pointer)
+	}
+	assert.Equal(t, []string{"#/allOf/0/required/0", "#/required/0"}, pointers)
+}
+
+func TestParseAllOfSeparate(t *testing.T) {
+	d, err := ParseToDiagramWithOptions(strings.NewReader(allOfDoc), "components.schemas.Charge", Options{SeparateAllOf: true})
+	require.NoError(t, err)
+
+	require.Len(t, d.Root.ComposedOf, 1)
+	base := d.Root.ComposedOf[0]
+	assert.Equal(t, Inheritance, base.Kind)
+	assert.Equal(t, "Amount", base.Object.Name)
+	assert.Len(t, base.Object.Properties, 2)
+
+	require.Len(t, d.Root.Properties, 1)
+	assert.Equal(t, "Type", d.Root.Properties[0].Name)
+}
+
+func TestParseOneOf(t *testing.T) {
+	doc := `
+components:
+  schemas:
+    Card:
+      type: object
+      properties:
+        Number:
+          type: string
+    Instrument:
+      type: object
+      properties:
+        Method:
+          oneOf:
+            - $ref: '#/components/schemas/Card'
+            - type: string
+            - type: object
+              properties:
+                Iban:
+                  type: string
+`
+	d, err := ParseToDiagram(strings.NewReader(doc), "components.schemas.Instrument")
+	require.NoError(t, err)
+
+	require.Len(t, d.Root.ComposedOf, 1)
+	variant := d.Root.ComposedOf[0].Object
+	assert.Equal(t, "Method", variant.Name)
+	assert.Equal(t, "oneOf", variant.Variant)
+
+	var names []string
+	for _, c := range variant.ComposedOf {
+		assert.Equal(t, OneOf, c.Kind)
+		names = append(names, c.Object.Name)
+	}
+	assert.Equal(t, []string{"Card", "string", "Option3"}, names)
+	assert.Len(t, variant.ComposedOf[2].Object.Properties, 1)
+	assert.Empty(t, d.Diagnostics)
+
+	// alternatives which only constrain the parent list the properties they require
+	d, err = ParseToDiagram(strings.NewReader(`
+type: object
+properties:
+  a:
+    type: string
+  b:
+    type: string
+oneOf:
+  - required: [a]
+  - required: [b]
+  - description: nothing to render
+`), "")
+	require.NoError(t, err)
+	require.Len(t, d.Root.ComposedOf, 2)
+	for i, name := range []string{"a", "b"} {
+		option := d.Root.ComposedOf[i].Object
+		assert.Equal(t, fmt.Sprintf("Option%d", i+1), option.Name)
+		require.Len(t, option.Properties, 1)
+		assert.Equal(t, name+" [1..1]", option.Properties[0].Label())
+	}
+
+	// scalar alternatives are supported shapes, even in strict mode
+	d, err = ParseToDiagramWithOptions(strings.NewReader(doc), "components.schemas.Instrument", Options{Strict: true})
+	require.NoError(t, err)
+	assert.Empty(t, d.Diagnostics)
+}
+
+func TestParseRecursiveReference(t *testing.T) {
+	doc := `
+components:
+  schemas:
+    Category:
+      type: object
+      properties:
+        Name:
+          type: string
+        Parent:
+          $ref: '#/components/schemas/Category'
+        Children:
+          type: array
+          items:
+            $ref: '#/components/schemas/Category'
+`
+	d, err := ParseToDiagram(strings.NewReader(doc), "components.schemas.Category")
+	require.NoError(t, err)
+
+	require.Len(t, d.Root.ComposedOf, 2)
+	for _, c := range d.Root.ComposedOf {
+		assert.Same(t, d.Root, c.Object.BackReference)
+		assert.Empty(t, c.Object.ComposedOf)
+	}
+	assert.Equal(t, "0..*", d.Root.ComposedOf[0].Relationship)
+
+	var buf strings.Builder
+	require.NoError(t, d.Render(&buf))
+	assert.Contains(t, buf.String(), "see Category")
+}
+
+func TestParseExternalReferences(t *testing.T) {
+	files := map[string]string{
+		"https://example.com/specs/common.yaml": `
+components:
+  schemas:
+    Amount:
+      type: object
+      properties:
+        Amount:
+          type: string
+        Currency:
+          $ref: './defs/currency.yaml'
+`,
+		"https://example.com/specs/defs/currency.yaml": `
+type: string
+pattern: '^[A-Z]{3}$'
+`,
+	}
+
+	var loads []string
+	loader := LoaderFunc(func(uri string) (io.ReadCloser, error) {
+		loads = append(loads, uri)
+		doc, ok := files[uri]
+		if !ok {
+			return nil, fmt.Errorf("not found: %s", uri)
+		}
+		return ioutil.NopCloser(strings.NewReader(doc)), nil
+	})
+
+	doc := `
+components:
+  schemas:
+    Payment:
+      type: object
+      properties:
+        Instructed:
+          $ref: 'common.yaml#/components/schemas/Amount'
+        Charged:
+          $ref: 'common.yaml#/components/schemas/Amount'
+`
+	opts := Options{BaseURI: "https://example.com/specs/payments.yaml", Loader: loader}
+	d, err := ParseToDiagramWithOptions(strings.NewReader(doc), "components.schemas.Payment", opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"https://example.com/specs/common.yaml",
+		"https://example.com/specs/defs/currency.yaml",
+	}, loads)
+
+	require.Len(t, d.Root.ComposedOf, 2)
+	amount := d.Root.ComposedOf[0].Object
+	require.Len(t, amount.Properties, 2)
+	assert.Equal(t, "^[A-Z]{3}$", amount.Properties[1].Pattern)
+}
+
+func TestParseIdentifiers(t *testing.T) {
+	doc := `
+$id: https://example.com/schemas/customer
+type: object
+properties:
+  Address:
+    $ref: '#address'
+  Balance:
+    $ref: money
+  Limit:
+    $ref: 'https://example.com/schemas/money#/properties/Amount'
+$defs:
+  address:
+    $anchor: address
+    type: object
+    properties:
+      Street:
+        type: string
+  money:
+    $id: money
+    type: object
+    properties:
+      Amount:
+        $ref: '#/$defs/amount'
+      Currency:
+        type: string
+    $defs:
+      amount:
+        type: string
+        pattern: '^\d+$'
+`
+	loader := LoaderFunc(func(uri string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("not loaded: %s", uri)
+	})
+	opts := Options{Loader: loader}
+	m, err := ParseToMapWithOptions(strings.NewReader(doc), "", opts)
+	require.NoError(t, err)
+	d, err := MakeDiagramWithOptions(m, "Customer", opts)
+	require.NoError(t, err)
+
+	require.Len(t, d.Root.ComposedOf, 2)
+	address, balance := d.Root.ComposedOf[0].Object, d.Root.ComposedOf[1].Object
+	assert.Equal(t, "Address", address.Name)
+	require.Len(t, address.Properties, 1)
+	assert.Equal(t, "Street", address.Properties[0].Name)
+
+	assert.Equal(t, "Balance", balance.Name)
+	require.Len(t, balance.Properties, 2)
+	assert.Equal(t, `^\d+$`, balance.Properties[0].Pattern)
+
+	require.Len(t, d.Root.Properties, 1)
+	assert.Equal(t, "Limit", d.Root.Properties[0].Name)
+	assert.Equal(t, `^\d+$`, d.Root.Properties[0].Pattern)
+}
+
+func TestParseSharedDefinitions(t *testing.T) {
+	doc := `
+components:
+  schemas:
+    Amount:
+      type: object
+      properties:
+        Amount:
+          type: string
+    Category:
+      type: object
+      properties:
+        Parent:
+          $ref: '#/components/schemas/Category'
+    Payment:
+      type: object
+      properties:
+        Category:
+          $ref: '#/components/schemas/Category'
+        Charges:
+          type: array
+          items:
+            $ref: '#/components/schemas/Amount'
+        Instructed:
+          $ref: '#/components/schemas/Amount'
+`
+	opts := Options{SharedDefinitions: true}
+	d, err := ParseToDiagramWithOptions(strings.NewReader(doc), "components.schemas.Payment", opts)
+	require.NoError(t, err)
+
+	require.Len(t, d.Root.ComposedOf, 3)
+	category, charges, instructed := d.Root.ComposedOf[0], d.Root.ComposedOf[1], d.Root.ComposedOf[2]
+	assert.Equal(t, "Amount", charges.Object.Name)
+	assert.Equal(t, "Charges", charges.Name)
+	assert.Equal(t, "Instructed", instructed.Name)
+	assert.Same(t, charges.Object, instructed.Object)
+
+	// the recursive reference is a connection to the object itself
+	require.Len(t, category.Object.ComposedOf, 1)
+	assert.Same(t, category.Object, category.Object.ComposedOf[0].Object)
+
+	var buf strings.Builder
+	require.NoError(t, d.Render(&buf))
+	assert.Equal(t, 3, strings.Count(buf.String(), "<rect"), "each object is rendered once")
+	assert.Equal(t, []*Object{category.Object, charges.Object}, d.Root.layoutChildren)
+}
+
+func TestParseNullableTypes(t *testing.T) {
+	doc := `
+type: object
+required: [Id, Name, Tags, Address]
+properties:
+  Id:
+    type: string
+  Name:
+    type: [string, "null"]
+  Nickname:
+    type: string
+    nullable: true
+  Code:
+    type: [string, integer]
+  Tags:
+    type: [array, "null"]
+    items:
+      type: string
+  Address:
+    type: object
+    nullable: true
+    properties:
+      Street:
+        type: string
+`
+	m, err := ParseToMap(strings.NewReader(doc), "")
+	require.NoError(t, err)
+	d, err := MakeDiagram(m, "Person")
+	require.NoError(t, err)
+
+	var labels []string
+	for _, p := range d.Root.Properties {
+		labels = append(labels, p.Label())
+	}
+	assert.Equal(t, []string{
+		"Code: string | integer [0..1]",
+		"Id [1..1]",
+		"Name: string | null [0..1]",
+		"Nickname: string | null [0..1]",
+		"Tags [0..*]",
+	}, labels)
+
+	require.Len(t, d.Root.ComposedOf, 1)
+	assert.Equal(t, "0..1", d.Root.ComposedOf[0].Relationship)
+}
+
+func TestParseMapEntries(t *testing.T) {
+	doc := `
+components:
+  schemas:
+    Label:
+      type: object
+      properties:
+        Text:
+          type: string
+    Metadata:
+      type: object
+      properties:
+        Tags:
+          type: object
+          additionalProperties:
+            type: string
+        Labels:
+          type: object
+          additionalProperties:
+            $ref: '#/components/schemas/Label'
+      patternProperties:
+        '^x-':
+          type: integer
+`
+	d, err := ParseToDiagram(strings.NewReader(doc), "components.schemas.Metadata")
+	require.NoError(t, err)
+
+	require.Len(t, d.Root.Properties, 1)
+	assert.Equal(t, "[key: /^x-/]: integer [0..*]", d.Root.Properties[0].Label())
+
+	require.Len(t, d.Root.ComposedOf, 2)
+	labels, tags := d.Root.ComposedOf[0].Object, d.Root.ComposedOf[1].Object
+	require.Len(t, labels.ComposedOf, 1)
+	entry := labels.ComposedOf[0]
+	assert.Equal(t, Map, entry.Kind)
+	assert.Equal(t, "[key: string]", entry.Name)
+	assert.Equal(t, "0..*", entry.Relationship)
+	assert.Equal(t, "LabelsValue", entry.Object.Name)
+	assert.Len(t, entry.Object.Properties, 1)
+
+	require.Len(t, tags.Properties, 1)
+	assert.Equal(t, "[key: string]: string [0..*]", tags.Properties[0].Label())
+}
+
+func TestParseNestedArraysAndTuples(t *testing.T) {
+	doc := `
+type: object
+required: [Matrix]
+properties:
+  Matrix:
+    type: array
+    items:
+      type: array
+      items:
+        type: number
+  Segments:
+    type: array
+    items:
+      type: array
+      items:
+        type: object
+        properties:
+          Start:
+            type: number
+  Point:
+    type: array
+    minItems: 2
+    prefixItems:
+      - type: number
+      - type: number
+      - type: object
+        properties:
+          Label:
+            type: string
+    items:
+      type: string
+`
+	m, err := ParseToMap(strings.NewReader(doc), "")
+	require.NoError(t, err)
+	d, err := MakeDiagram(m, "Shape")
+	require.NoError(t, err)
+
+	require.Len(t, d.Root.Properties, 1)
+	assert.Equal(t, "Matrix [0..* of 0..*]", d.Root.Properties[0].Label())
+
+	require.Len(t, d.Root.ComposedOf, 2)
+	point, segments := d.Root.ComposedOf[0], d.Root.ComposedOf[1]
+	assert.Equal(t, "0..* of 0..*", segments.Relationship)
+	assert.Len(t, segments.Object.Properties, 1)
+
+	assert.Equal(t, "0..1", point.Relationship)
+	var labels []string
+	for _, p := range point.Object.Properties {
+		labels = append(labels, p.Label())
+	}
+	assert.Equal(t, []string{"[0]: number [1..1]", "[1]: number [1..1]", "[3..]: string [0..*]"}, labels)
+	require.Len(t, point.Object.ComposedOf, 1)
+	assert.Equal(t, "[2]", point.Object.ComposedOf[0].Object.Name)
+
+	// numbers decoded by encoding/json are float64
+	var tuple map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "array",
+		"minItems": 1,
+		"items": [{"type": "number"}, {"type": "number"}],
+		"additionalItems": true
+	}`), &tuple))
+	d, err = MakeDiagram(map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"Pair": tuple},
+	}, "Shape")
+	require.NoError(t, err)
+	require.Len(t, d.Root.ComposedOf, 1)
+	labels = nil
+	for _, p := range d.Root.ComposedOf[0].Object.Properties {
+		labels = append(labels, p.Label())
+	}
+	assert.Equal(t, []string{"[0]: number [1..1]", "[1]: number [0..1]", "[2..] [0..*]"}, labels)
+
+	// a shared tuple lists its positions once, however often it's used
+	shared := `
+components:
+  schemas:
+    Point:
+      type: array
+      prefixItems:
+        - type: number
+        - type: number
+    Line:
+      type: object
+      properties:
+        from:
+          $ref: '#/components/schemas/Point'
+        to:
+          $ref: '#/components/schemas/Point'
+        via:
+          type: array
+          items:
+            $ref: '#/components/schemas/Point'
+`
+	d, err = ParseToDiagramWithOptions(strings.NewReader(shared), "Line", Options{SharedDefinitions: true})
+	require.NoError(t, err)
+	require.Len(t, d.Root.ComposedOf, 3)
+	shape := d.Root.ComposedOf[0].Object
+	for _, c := range d.Root.ComposedOf {
+		assert.Same(t, shape, c.Object)
+	}
+	assert.Len(t, shape.Properties, 2)
+}
+
+func TestParsePropertyConstraints(t *testing.T) {
+	doc := `
+type: object
+properties:
+  Status:
+    type: string
+    description: Status of the payment
+    format: code
+    minLength: 1
+    maxLength: 4
+    enum: [Pending, Done]
+    default: Pending
+    deprecated: true
+    readOnly: true
+  Amount:
+    type: number
+    minimum: 0.01
+    examples: [12.5]
+`
+	m, err := ParseToMap(strings.NewReader(doc), "")
+	require.NoError(t, err)
+	d, err := MakeDiagram(m, "Payment")
+	require.NoError(t, err)
+
+	require.Len(t, d.Root.Properties, 2)
+	amount, status := d.Root.Properties[0], d.Root.Properties[1]
+
+	assert.Equal(t, 0.01, *amount.Minimum)
+	assert.Nil(t, amount.Maximum)
+	assert.Equal(t, 12.5, amount.Example)
+
+	assert.Equal(t, "string", status.Type)
+	assert.Equal(t, "code", status.Format)
+	assert.Equal(t, []string{"Pending", "Done"}, status.Enum)
+	assert.Equal(t, 4.0, *status.MaxLength)
+	assert.True(t, status.Deprecated)
+	assert.True(t, status.ReadOnly)
+	assert.False(t, status.WriteOnly)
+	assert.Equal(t, "Type: string\nFormat: code\nMinLength: 1\nMaxLength: 4\n"+
+		"Description: Status of the payment\nValues:\n - Pending\n - Done\n"+
+		"Default: Pending\nDeprecated\nRead only\n", status.Tooltip())
+}
+
+func TestParseEnumBoxes(t *testing.T) {
+	doc := `
+components:
+  schemas:
+    SchemeName:
+      type: string
+      x-namespaced-enum:
+        - UK.OBIE.IBAN
+        - UK.OBIE.SortCodeAccountNumber
+    Payment:
+      type: object
+      properties:
+        Creditor:
+          $ref: '#/components/schemas/SchemeName'
+        Debtor:
+          $ref: '#/components/schemas/SchemeName'
+        Status:
+          type: string
+          enum: [Pending, Done]
+`
+	opts := Options{EnumBoxes: true}
+	d, err := ParseToDiagramWithOptions(strings.NewReader(doc), "components.schemas.Payment", opts)
+	require.NoError(t, err)
+
+	assert.Len(t, d.Root.Properties, 3)
+	require.Len(t, d.Root.ComposedOf, 3)
+	creditor, debtor, status := d.Root.ComposedOf[0], d.Root.ComposedOf[1], d.Root.ComposedOf[2]
+	assert.Equal(t, Enumeration, creditor.Kind)
+	assert.Equal(t, "Creditor", creditor.Name)
+	assert.Equal(t, "SchemeName", creditor.Object.Name)
+	assert.Equal(t, "enumeration", creditor.Object.Stereotype())
+	assert.Same(t, creditor.Object, debtor.Object)
+	assert.Equal(t, []string{"Pending", "Done"}, status.Object.Values)
+	assert.Empty(t, status.Name)
+
+	var buf strings.Builder
+	require.NoError(t, d.Render(&buf))
+	assert.Contains(t, buf.String(), "UK.OBIE.SortCodeAccountNumber")
+	assert.Equal(t, 3, strings.Count(buf.String(), "<rect"))
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	doc := `
+components:
+  schemas:
+    Amount:
+      type: object
+      properties:
+        Currency: USD
+        Value:
+          type: number
+      additionalProperties: 42
+    Payment:
+      type: object
+      properties:
+        Amount:
+          $ref: '#/components/schemas/Amount'
+        Payee:
+          $ref: '#/components/schemas/Party'
+`
+	d, err := ParseToDiagram(strings.NewReader(doc), "components.schemas.Amount")
+	require.NoError(t, err)
+	assert.Equal(t, []Diagnostic{
+		{Warning, "#/components/schemas/Amount/properties/Currency", "the schema of 'Currency' is not an object"},
+		{Warning, "#/components/schemas/Amount/additionalProperties", "additionalProperties must be a schema or a boolean"},
+	}, d.Diagnostics)
+
+	_, err = ParseToDiagram(strings.NewReader(doc), "components.schemas.Payment")
+	var diag *Diagnostic
+	require.True(t, errors.As(err, &diag))
+	assert.Equal(t, Error, diag.Severity)
+	assert.Equal(t, "#/components/schemas/Payment/properties/Payee", diag.Pointer)
+	assert.Contains(t, diag.Message, "'#/components/schemas/Party' not found")
+
+	_, err = ParseToDiagram(strings.NewReader(doc), "components.schemas.Missing")
+	require.True(t, errors.As(err, &diag))
+	assert.Equal(t, "#/components/schemas/Missing", diag.Pointer)
+
+	_, err = ParseToDiagram(strings.NewReader("type: [object"), "")
+	require.True(t, errors.As(err, &diag))
+	assert.Equal(t, "#", diag.Pointer)
+
+	// only the selected schema is expected to have properties
+	d, err = ParseToDiagram(strings.NewReader(`type: string`), "")
+	require.NoError(t, err)
+	assert.Equal(t, []Diagnostic{
+		{Warning, "#", "schema of type 'string' has no properties to render"},
+	}, d.Diagnostics)
+}
+
+func TestParseStrictAndLenient(t *testing.T) {
+	doc := `
+components:
+  schemas:
+    Order:
+      type: object
+      properties:
+        Customer:
+          properties:
+            Name:
+              type: string
+        Lines:
+          type: array
+          items: [not, a, schema]
+        Notes: 42
+`
+	d, err := ParseToDiagram(strings.NewReader(doc), "components.schemas.Order")
+	require.NoError(t, err)
+	require.Len(t, d.Root.ComposedOf, 2)
+	customer, lines := d.Root.ComposedOf[0].Object, d.Root.ComposedOf[1].Object
+	assert.Empty(t, lines.Properties)
+	assert.Equal(t, "Customer", customer.Name)
+	require.Len(t, customer.Properties, 1)
+	assert.Equal(t, "Name", customer.Properties[0].Name)
+	var pointers []string
+	for _, diag := range d.Diagnostics {
+		assert.Equal(t, Warning, diag.Severity)
+		pointers = append(pointers, diag.Pointer)
+	}
+	assert.Equal(t, []string{
+		"#/components/schemas/Order/properties/Lines/items/0",
+		"#/components/schemas/Order/properties/Lines/items/1",
+		"#/components/schemas/Order/properties/Lines/items/2",
+		"#/components/schemas/Order/properties/Notes",
+	}, pointers)
+
+	opts := Options{Strict: true}
+	_, err = ParseToDiagramWithOptions(strings.NewReader(doc), "components.schemas.Order", opts)
+	var diag *Diagnostic
+	require.True(t, errors.As(err, &diag))
+	assert.Equal(t, Error, diag.Severity)
+	assert.Equal(t, "#/components/schemas/Order/properties/Customer", diag.Pointer)
+}
+
+func TestParseDiscriminatorMapping(t *testing.T) {
+	doc := `
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [petType]
+      properties:
+        petType:
+          type: string
+      discriminator:
+        propertyName: petType
+        mapping:
+          dog: '#/components/schemas/Dog'
+          cat: Cat
+    Dog:
+      allOf:
+        - $ref: '#/components/schemas/Pet'
+        - type: object
+          properties:
+            Bark:
+              type: boolean
+    Cat:
+      allOf:
+        - $ref: '#/components/schemas/Pet'
+        - type: object
+          properties:
+            Lives:
+              type: integer
+    Owner:
+      type: object
+      properties:
+        Pet:
+          oneOf:
+            - $ref: '#/components/schemas/Dog'
+            - $ref: '#/components/schemas/Cat'
+          discriminator:
+            propertyName: petType
+            mapping:
+              woof: '#/components/schemas/Dog'
+`
+	opts := Options{Strict: true}
+	d, err := ParseToDiagramWithOptions(strings.NewReader(doc), "Pet", opts)
+	require.NoError(t, err)
+	assert.Empty(t, d.Diagnostics)
+
+	require.Len(t, d.Root.ComposedOf, 2)
+	cat, dog := d.Root.ComposedOf[0], d.Root.ComposedOf[1]
+	for _, c := range []Composition{cat, dog} {
+		assert.Equal(t, Subtype, c.Kind)
+		assert.Equal(t, "petType", c.Name)
+		require.Len(t, c.Object.Properties, 1)
+	}
+	assert.Equal(t, "cat", cat.Value)
+	assert.Equal(t, "Cat", cat.Object.Name)
+	assert.Equal(t, "Lives", cat.Object.Properties[0].Name)
+	assert.Equal(t, "dog", dog.Value)
+	assert.Equal(t, "Bark", dog.Object.Properties[0].Name)
+
+	var buf strings.Builder
+	require.NoError(t, d.Render(&buf))
+	assert.Contains(t, buf.String(), "petType = dog")
+	assert.Contains(t, buf.String(), `marker-start="url(#HollowTriangleStart)"`)
+
+	d, err = ParseToDiagramWithOptions(strings.NewReader(doc), "Owner", opts)
+	require.NoError(t, err)
+	variant := d.Root.ComposedOf[0].Object
+	require.Len(t, variant.ComposedOf, 2)
+	assert.Equal(t, OneOf, variant.ComposedOf[0].Kind)
+	assert.Equal(t, "woof", variant.ComposedOf[0].Value)
+	assert.Empty(t, variant.ComposedOf[1].Value)
+}
+
+func TestParseViews(t *testing.T) {
+	doc := `
+type: object
+properties:
+  Id:
+    type: string
+    readOnly: true
+  Password:
+    type: string
+    writeOnly: true
+  Name:
+    type: string
+  Audit:
+    type: object
+    readOnly: true
+    properties:
+      Created:
+        type: string
+  Tags:
+    type: array
+    readOnly: true
+    items:
+      type: string
+`
+	names := func(view View) ([]string, []string) {
+		d, err := ParseToDiagramWithOptions(strings.NewReader(doc), "", Options{View: view})
+		require.NoError(t, err)
+		var properties, objects []string
+		for _, p := range d.Root.Properties {
+			properties = append(properties, p.Name)
+		}
+		for _, c := range d.Root.ComposedOf {
+			objects = append(objects, c.Object.Name)
+		}
+		return properties, objects
+	}
+
+	properties, objects := names(ViewRequest)
+	assert.Equal(t, []string{"Name", "Password"}, properties)
+	assert.Empty(t, objects)
+
+	properties, objects = names(ViewResponse)
+	assert.Equal(t, []string{"Id", "Name", "Tags"}, properties)
+	assert.Equal(t, []string{"Audit"}, objects)
+
+	d, err := ParseToDiagramWithOptions(strings.NewReader(doc), "", Options{})
+	require.NoError(t, err)
+	require.Len(t, d.Root.Properties, 4)
+	assert.Equal(t, "Id [0..1] {readOnly}", d.Root.Properties[0].Label())
+	assert.Equal(t, "Password [0..1] {writeOnly}", d.Root.Properties[2].Label())
+	assert.Equal(t, "Tags [0..*] {readOnly}", d.Root.Properties[3].Label())
+	require.Len(t, d.Root.ComposedOf, 1)
+	assert.Equal(t, "readOnly", d.Root.ComposedOf[0].Access)
+
+	var buf strings.Builder
+	require.NoError(t, d.Render(&buf))
+	assert.Contains(t, buf.String(), `font-style="italic"`)
+	assert.Contains(t, buf.String(), "0..1 {readOnly}")
+
+	_, err = ParseView("both")
+	assert.Error(t, err)
+}
+
+func TestParseDeprecated(t *testing.T) {
+	doc := `
+type: object
+properties:
+  Name:
+    type: string
+  Nickname:
+    type: string
+    deprecated: true
+  Legacy:
+    type: object
+    deprecated: true
+    properties:
+      Code:
+        type: string
+  Contact:
+    oneOf:
+      - title: Email
+        type: object
+        properties:
+          Address:
+            type: string
+      - title: Fax
+        type: object
+        deprecated: true
+        properties:
+          Number:
+            type: string
+`
+	d, err := ParseToDiagram(strings.NewReader(doc), "")
+	require.NoError(t, err)
+	require.Len(t, d.Root.Properties, 2)
+	assert.True(t, d.Root.Properties[1].Deprecated)
+	require.Len(t, d.Root.ComposedOf, 2)
+	assert.Equal(t, "Contact", d.Root.ComposedOf[0].Object.Name)
+	assert.Len(t, d.Root.ComposedOf[0].Object.ComposedOf, 2)
+	assert.True(t, d.Root.ComposedOf[1].Object.Deprecated)
+
+	var buf strings.Builder
+	require.NoError(t, d.Render(&buf))
+	assert.Contains(t, buf.String(), `text-decoration="line-through">deprecated</text>`)
+
+	d, err = ParseToDiagramWithOptions(strings.NewReader(doc), "", Options{HideDeprecated: true})
+	require.NoError(t, err)
+	require.Len(t, d.Root.Properties, 1)
+	assert.Equal(t, "Name", d.Root.Properties[0].Name)
+	require.Len(t, d.Root.ComposedOf, 1)
+	contact := d.Root.ComposedOf[0].Object
+	require.Len(t, contact.ComposedOf, 1)
+	assert.Equal(t, "Email", contact.ComposedOf[0].Object.Name)
+
+	buf.Reset()
+	require.NoError(t, d.Render(&buf))
+	assert.NotContains(t, buf.String(), "line-through")
+}
+
+func TestParseConditions(t *testing.T) {
+	doc := `
+type: object
+required: [SchemeName]
+properties:
+  SchemeName:
+    type: string
+  CreditorAccount:
+    type: string
+  Iban:
+    type: string
+  Bic:
+    type: string
+if:
+  properties:
+    SchemeName:
+      const: IBAN
+then:
+  required: [Iban]
+else:
+  required: [CreditorAccount]
+dependentRequired:
+  Bic: [Iban]
+dependentSchemas:
+  CreditorAccount:
+    properties:
+      Currency:
+        type: string
+        pattern: "^[A-Z]{3}$"
+    required: [Currency]
+`
+	d, err := ParseToDiagram(strings.NewReader(doc), "")
+	require.NoError(t, err)
+
+	type box struct {
+		label, stereotype string
+		properties        []string
+	}
+	var boxes []box
+	for _, c := range d.Root.ComposedOf {
+		assert.Equal(t, Conditional, c.Kind)
+		var properties []string
+		for _, p := range c.Object.Properties {
+			properties = append(properties, p.Label())
+		}
+		boxes = append(boxes, box{c.Name, c.Object.Stereotype(), properties})
+	}
+	assert.Equal(t, []box{
+		{"if SchemeName = IBAN", "then", []string{"Iban [1..1]"}},
+		{"unless SchemeName = IBAN", "else", []string{"CreditorAccount [1..1]"}},
+		{"if Bic present", "dependent", []string{"Iban [1..1]"}},
+		{"if CreditorAccount present", "dependent", []string{"Currency [1..1]"}},
+	}, boxes)
+
+	var buf strings.Builder
+	require.NoError(t, d.Render(&buf))
+	assert.Contains(t, buf.String(), "if SchemeName = IBAN")
+	assert.Contains(t, buf.String(), "«then»")
+}
+
+func TestParseBoundedCardinality(t *testing.T) {
+	doc := `
+type: object
+required: [Lines, Tags, Notes, Scores]
+properties:
+  Lines:
+    type: array
+    minItems: 1
+    maxItems: 3
+    items:
+      type: object
+      properties:
+        Amount:
+          type: string
+  Tags:
+    type: array
+    maxItems: 5
+    items:
+      type: string
+  Notes:
+    type: array
+    nullable: true
+    minItems: 2
+    items:
+      type: string
+  Scores:
+    type: array
+    minItems: 0
+    items:
+      type: array
+      minItems: 2
+      maxItems: 2
+      items:
+        type: number
+  Codes:
+    type: array
+    minItems: 1
+    maxItems: 2
+    items:
+      type: string
+  Labels:
+    type: object
+    minProperties: 1
+    maxProperties: 10
+    additionalProperties:
+      type: string
+`
+	d, err := ParseToDiagram(strings.NewReader(doc), "")
+	require.NoError(t, err)
+
+	rels := map[string]string{}
+	for _, p := range d.Root.Properties {
+		rels[p.Name] = p.Relationship
+	}
+	for _, c := range d.Root.ComposedOf {
+		rels[c.Object.Name] = c.Relationship
+	}
+	assert.Equal(t, map[string]string{
+		"Lines":  "1..3",
+		"Tags":   "0..5",
+		"Notes":  "0..*",
+		"Scores": "0..* of 2..2",
+		"Codes":  "0..2",
+		"Labels": "0..1",
+	}, rels)
+
+	labels := d.Root.ComposedOf[0].Object
+	require.Equal(t, "Labels", labels.Name)
+	require.Len(t, labels.Properties, 1)
+	assert.Equal(t, "1..10", labels.Properties[0].Relationship)
+}
+
+func TestParseRequiredFields(t *testing.T) {
+	doc := `
+type: object
+required: [id, Legacy, 42]
+x-required: [Tag]
+properties:
+  id:
+    type: string
+  ID:
+    type: string
+  Name:
+    type: string
+    required: true
+  Code:
+    type: string
+    x-required: true
+  Tag:
+    type: string
+  Note:
+    type: string
+patternProperties:
+  "^x-":
+    type: string
+`
+	d, err := ParseToDiagram(strings.NewReader(doc), "")
+	require.NoError(t, err)
+
+	rels := map[string]string{}
+	for _, p := range d.Root.Properties {
+		rels[p.Name] = p.Relationship
+	}
+	assert.Equal(t, map[string]string{
+		"id":           "1..1",
+		"ID":           "0..1",
+		"Name":         "1..1",
+		"Code":         "1..1",
+		"Tag":          "1..1",
+		"Note":         "0..1",
+		"[key: /^x-/]": "0..*",
+	}, rels)
+
+	var messages []string
+	for _, diag := range d.Diagnostics {
+		messages = append(messages, diag.Error())
+	}
+	assert.Equal(t, []string{
+		"warning at '#': required property 'Legacy' is not defined",
+		"warning at '#/required/2': required name is not a string: 42",
+	}, messages)
+
+	_, err = ParseToDiagramWithOptions(strings.NewReader(doc), "", Options{Strict: true})
+	assert.EqualError(t, err, "error at '#/required/2': required name is not a string: 42")
+}
+
+func TestParseRequiredInheritedFields(t *testing.T) {
+	doc := `
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        petType:
+          type: string
+      discriminator:
+        propertyName: petType
+        mapping:
+          dog: Dog
+    Dog:
+      allOf:
+        - $ref: '#/components/schemas/Pet'
+        - type: object
+          required: [petType, Bark]
+          properties:
+            Bark:
+              type: boolean
+    Named:
+      type: object
+      properties:
+        Name:
+          type: string
+    Owner:
+      allOf:
+        - $ref: '#/components/schemas/Named'
+        - type: object
+          required: [Name, Phone]
+          properties:
+            Phone:
+              type: string
+`
+	for _, opts := range []Options{{}, {SeparateAllOf: true}} {
+		for _, name := range []string{"Pet", "Owner"} {
+			d, err := ParseToDiagramWithOptions(strings.NewReader(doc), name, opts)
+			require.NoError(t, err)
+			assert.Empty(t, d.Diagnostics, name)
+		}
+	}
+}
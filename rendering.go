@@ -1,6 +1,7 @@
 package js2svg
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -11,12 +12,22 @@ const (
 	strokeColor     = "darkslategrey"
 	nameColor       = "royalblue"
 	propertyColor   = "seagreen"
+	accessColor     = "slategrey" // readOnly and writeOnly properties
+	deprecatedColor = "darkgrey"
 	connectorColor  = strokeColor
 
 	headers = `<?xml version="1.0" standalone="no"?>
 <svg xmlns="http://www.w3.org/2000/svg" font-family="monospace" width="%vem" height="%vem">`
 	footer = `</svg>`
 
+	titleHeight   = 2.0
+	titleTemplate = `
+<text style="font-weight:bold" x="%vem" y="%vem" fill="` + nameColor + `">%s</text>`
+
+	legendHeight   = 2.0
+	legendTemplate = `
+<text x="%vem" y="%vem" fill="` + deprecatedColor + `" text-decoration="line-through">deprecated</text>`
+
 	defs = `<defs>
     <marker id="Triangle"
       viewBox="0 0 10 10" refX="0" refY="5" 
@@ -33,44 +44,95 @@ const (
       orient="auto">
       <path d="M 0 5 L 8 10 L 16 5 L 8 0 z" />
     </marker>   
+
+    <marker id="HollowTriangle"
+      viewBox="0 0 10 10" refX="0" refY="5" 
+      markerUnits="strokeWidth"
+      markerWidth="15" markerHeight="10"
+      orient="auto">
+      <path d="M 0 0 L 10 5 L 0 10 z" fill="white" stroke="black" />
+    </marker>
+
+    <marker id="HollowTriangleStart"
+      viewBox="0 0 10 10" refX="0" refY="5" 
+      markerUnits="strokeWidth"
+      markerWidth="15" markerHeight="10"
+      orient="auto">
+      <path d="M 10 0 L 0 5 L 10 10 z" fill="white" stroke="black" />
+    </marker>
 </defs>`
 )
 
 var (
 	objectTemplate = fmt.Sprintf(`
-<rect x="{{.Position.X}}em" y="{{.Position.Y}}em" width="{{.Width}}em" height="{{.Height}}em" fill="%s" stroke="%s" stroke-width="2"/>
-	<text style="font-weight:bold" text-anchor="middle" x="{{.NamePosition.X}}em" y="{{.NamePosition.Y}}em" fill="%s">
+<rect id="{{.ElementID}}" x="{{.Position.X}}em" y="{{.Position.Y}}em" width="{{.Width}}em" height="{{.Height}}em" fill="%s" stroke="%s" stroke-width="2"{{if .BackReference}} stroke-dasharray="4 2"{{end}}/>
+	<text style="font-weight:bold" text-anchor="middle" x="{{.NamePosition.X}}em" y="{{.NamePosition.Y}}em"{{if .Deprecated}} fill="%[6]s" text-decoration="line-through"{{else}} fill="%[3]s"{{end}}>
 		<title>{{.Description}}</title>
-		{{.Name}}
+		{{.Name}}{{with .Stereotype}} «{{.}}»{{end}}
 	</text>
 {{range $i, $prop := .Properties}}
-	<text x="{{($.FieldPosition $i).X}}em" y="{{($.FieldPosition $i).Y}}em" fill="%s">
-	{{if .Description}}<title>{{.Description}}</title>{{end}}
-	{{.Name}} [{{.Relationship}}]
+	<text x="{{($.FieldPosition $i).X}}em" y="{{($.FieldPosition $i).Y}}em" fill="{{if .Deprecated}}%[6]s{{else if .Access}}%[5]s{{else}}%[4]s{{end}}"{{if .Access}} font-style="italic"{{end}}{{if .Deprecated}} text-decoration="line-through"{{end}}>
+	{{with .Tooltip}}<title>{{.}}</title>{{end}}
+	{{$.PropertyLabel .}}
 	</text>
-{{end}}`, objectFillColor, strokeColor, strokeColor, propertyColor)
+{{end}}
+{{range $i, $value := .Values}}
+	<text x="{{($.FieldPosition (len $.Properties | add $i)).X}}em" y="{{($.FieldPosition (len $.Properties | add $i)).Y}}em" fill="%[4]s">{{$value}}</text>
+{{end}}
+{{with .BackReference}}
+	<a href="#{{.ElementID}}">
+	<text x="{{($.FieldPosition (len $.Properties)).X}}em" y="{{($.FieldPosition (len $.Properties)).Y}}em" fill="%[4]s">see {{.Name}}</text>
+	</a>
+{{end}}`, objectFillColor, strokeColor, strokeColor, propertyColor, accessColor, deprecatedColor)
 
 	connectorTemplate = fmt.Sprintf(`
-<line x1="{{(index . 0).Start.X}}em" y1="{{(index . 0).Start.Y}}em" x2="{{(index . 0).Stop.X}}em" y2="{{(index . 0).Stop.Y}}em" stroke="%[1]s" marker-start="url(#Diamond)"/>
-<line x1="{{(index . 1).Start.X}}em" y1="{{(index . 1).Start.Y}}em" x2="{{(index . 1).Stop.X}}em" y2="{{(index . 1).Stop.Y}}em" stroke="%[1]s" />
-<line x1="{{(index . 2).Start.X}}em" y1="{{(index . 2).Start.Y}}em" x2="{{(index . 2).Stop.X}}em" y2="{{(index . 2).Stop.Y}}em" stroke="%[1]s" marker-end="url(#Triangle)"/>
-<text x="{{textPosition.X}}em" y="{{textPosition.Y}}em">{{relationship}}</text>`, strokeColor)
+<line x1="{{(index . 0).Start.X}}em" y1="{{(index . 0).Start.Y}}em" x2="{{(index . 0).Stop.X}}em" y2="{{(index . 0).Stop.Y}}em" stroke="%[1]s"{{with dashArray}} stroke-dasharray="{{.}}"{{end}}{{with startMarker}} marker-start="url(#{{.}})"{{end}}/>
+<line x1="{{(index . 1).Start.X}}em" y1="{{(index . 1).Start.Y}}em" x2="{{(index . 1).Stop.X}}em" y2="{{(index . 1).Stop.Y}}em" stroke="%[1]s"{{with dashArray}} stroke-dasharray="{{.}}"{{end}} />
+<line x1="{{(index . 2).Start.X}}em" y1="{{(index . 2).Start.Y}}em" x2="{{(index . 2).Stop.X}}em" y2="{{(index . 2).Stop.Y}}em" stroke="%[1]s"{{with dashArray}} stroke-dasharray="{{.}}"{{end}}{{with endMarker}} marker-end="url(#{{.}})"{{end}}/>
+<text x="{{textPosition.X}}em" y="{{textPosition.Y}}em">{{relationship}}</text>
+{{with role}}<text x="{{rolePosition.X}}em" y="{{rolePosition.Y}}em" font-size="smaller">{{.}}</text>{{end}}`, strokeColor)
 )
 
 // Diagram to be rendered
 type Diagram struct {
 	Root *Object
+	// InlineTypes renders the properties in UML style: "name: type [cardinality]"
+	InlineTypes bool
+	// Diagnostics are the warnings found while parsing the document
+	Diagnostics []Diagnostic
+	// Title is rendered above the diagram if set
+	Title string
 }
 
 // Render the diagram writing the SVG document on the dst
 func (d *Diagram) Render(dst io.Writer) error {
+	if d.Root == nil {
+		return errors.New("the diagram has no root object")
+	}
+
 	// recalculate child positions
 	d.Root.Position.X = 1 // 1em margin
 	d.Root.Position.Y = 1 //
+	if d.Title != "" {
+		d.Root.Position.Y += titleHeight
+	}
+	for _, o := range d.Root.objects() {
+		o.inlineTypes = d.InlineTypes
+	}
+	d.Root.arrange()
 	d.Root.calculateChildPositions()
 
 	// write the header
-	h := fmt.Sprintf(headers, d.Root.totalWidth(), d.Root.totalHeight()+1.0) // add 1em margin on the bottom
+	width := d.Root.totalWidth()
+	if w := d.Root.Position.X + float64(len(d.Title))*0.8; w > width {
+		width = w
+	}
+	height := d.Root.totalHeight() + d.Root.Position.Y // add 1em margin on the bottom
+	deprecated := d.Root.hasDeprecated()
+	if deprecated {
+		height += legendHeight
+	}
+	h := fmt.Sprintf(headers, width, height)
 	_, err := dst.Write([]byte(h))
 	if err != nil {
 		return err
@@ -82,6 +144,13 @@ func (d *Diagram) Render(dst io.Writer) error {
 		return err
 	}
 
+	if d.Title != "" {
+		title := fmt.Sprintf(titleTemplate, d.Root.Position.X, titleHeight, template.HTMLEscapeString(d.Title))
+		if _, err := dst.Write([]byte(title)); err != nil {
+			return err
+		}
+	}
+
 	// render the objects
 	if err := renderObject(dst, d.Root); err != nil {
 		return err
@@ -96,6 +165,14 @@ func (d *Diagram) Render(dst io.Writer) error {
 		return err
 	}
 
+	// the legend explains the styling of the deprecated elements
+	if deprecated {
+		legend := fmt.Sprintf(legendTemplate, d.Root.Position.X, height-1)
+		if _, err := dst.Write([]byte(legend)); err != nil {
+			return err
+		}
+	}
+
 	_, err = dst.Write([]byte(footer))
 	return err
 }
@@ -105,30 +182,89 @@ type line struct {
 	Stop  Position
 }
 
-func renderConnection(dst io.Writer, from, to *Object) error {
+func renderConnection(dst io.Writer, from *Object, comp Composition) error {
+	to := comp.Object
+
+	// segment points
+	sp1 := Position{from.Position.X + from.Width(), from.Position.Y + 1.0}
+	sp2 := Position{sp1.X + 2, sp1.Y}
+	sp3 := Position{sp2.X, to.Position.Y + 1.0}
+	sp4 := Position{to.Position.X - 0.8, sp3.Y}
+	if sp4.X < sp3.X {
+		// the object is placed elsewhere on the left: connect to its right side
+		sp4.X = to.Position.X + to.Width() + 0.8
+	}
+
+	// a shared object has many incoming connections, their labels
+	// go to the other end of the segment to avoid overlapping
+	textPosition := Position{to.Position.X - 3.5, to.Position.Y + 0.5}
+	switch {
+	case !from.isLayoutChild(to):
+		textPosition = Position{sp3.X + 0.3, sp3.Y - 0.5}
+	case comp.Value != "" || comp.Access != "" || comp.Kind == Conditional:
+		// discriminator, access and condition labels are too long for the gap, they go above the box
+		textPosition = Position{to.Position.X, to.Position.Y - 0.3}
+	}
+
 	functions := template.FuncMap(map[string]interface{}{
 		"relationship": func() string {
-			for _, comp := range from.ComposedOf {
-				if comp.Object == to {
-					return comp.Relationship
-				}
+			if comp.Value != "" && comp.Name != "" {
+				return fmt.Sprintf("%s = %s", comp.Name, comp.Value)
+			} else if comp.Value != "" {
+				return comp.Value
+			}
+			switch comp.Kind {
+			case OneOf:
+				return "one of"
+			case AnyOf:
+				return "any of"
+			case Conditional:
+				return comp.Name
+			}
+			if comp.Access != "" {
+				return fmt.Sprintf("%s {%s}", comp.Relationship, comp.Access)
+			}
+			return comp.Relationship
+		},
+		"startMarker": func() string {
+			switch comp.Kind {
+			case Subtype:
+				return "HollowTriangleStart"
+			case Inheritance, OneOf, AnyOf, Enumeration, Conditional:
+				return ""
+			}
+			return "Diamond"
+		},
+		"dashArray": func() string {
+			switch comp.Kind {
+			case OneOf, AnyOf, Enumeration, Conditional:
+				return "4 2"
 			}
 			return ""
 		},
+		"endMarker": func() string {
+			switch comp.Kind {
+			case Inheritance:
+				return "HollowTriangle"
+			case Subtype:
+				return ""
+			}
+			return "Triangle"
+		},
 		"textPosition": func() Position {
-			return Position{
-				X: to.Position.X - 3.5,
-				Y: to.Position.Y + 0.5,
+			return textPosition
+		},
+		"role": func() string {
+			if comp.Value != "" || comp.Kind == Conditional {
+				return "" // in the label
 			}
+			return comp.Name
+		},
+		"rolePosition": func() Position {
+			return Position{sp3.X + 0.3, sp3.Y + 1.0}
 		},
 	})
 
-	// segment points
-	sp1 := Position{from.Position.X + from.Width(), from.Position.Y + 1.0}
-	sp2 := Position{sp1.X + 2, sp1.Y}
-	sp3 := Position{sp2.X, to.Position.Y + 1.0}
-	sp4 := Position{to.Position.X - 0.8, sp3.Y}
-
 	lines := []line{
 		{sp1, sp2},
 		{sp2, sp3},
@@ -147,7 +283,11 @@ func renderConnection(dst io.Writer, from, to *Object) error {
 }
 
 func renderObject(dst io.Writer, o *Object) error {
-	tmpl, err := template.New("object").Parse(objectTemplate)
+	functions := template.FuncMap(map[string]interface{}{
+		"add": func(a, b int) int { return a + b },
+	})
+
+	tmpl, err := template.New("object").Funcs(functions).Parse(objectTemplate)
 	if err != nil {
 		return err
 	}
@@ -159,11 +299,11 @@ func renderObject(dst io.Writer, o *Object) error {
 }
 
 func renderChildObjects(dst io.Writer, o *Object) error {
-	for _, c := range o.ComposedOf {
-		if err := renderObject(dst, c.Object); err != nil {
+	for _, child := range o.layoutChildren {
+		if err := renderObject(dst, child); err != nil {
 			return err
 		}
-		if err := renderChildObjects(dst, c.Object); err != nil {
+		if err := renderChildObjects(dst, child); err != nil {
 			return err
 		}
 	}
@@ -172,11 +312,13 @@ func renderChildObjects(dst io.Writer, o *Object) error {
 }
 
 func renderConnections(dst io.Writer, o *Object) error {
+	// this object's connections
 	for _, c := range o.ComposedOf {
-		// this connection
-		renderConnection(dst, o, c.Object)
-		// child's connections
-		renderConnections(dst, c.Object)
+		renderConnection(dst, o, c)
+	}
+	// children's connections
+	for _, child := range o.layoutChildren {
+		renderConnections(dst, child)
 	}
 	return nil
 }
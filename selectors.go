@@ -1,8 +1,10 @@
 package js2svg
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -20,9 +22,36 @@ func unmarshalSrc(src io.Reader) (map[string]interface{}, error) {
 		return nil, err
 	}
 
-	return dst, nil
+	return stringKeys(dst).(map[string]interface{}), nil
 }
 
+// stringKeys converts the YAML mappings with non-string keys, like the unquoted
+// status codes of OpenAPI responses, to maps with string keys
+func stringKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, value := range t {
+			m[fmt.Sprint(k)] = stringKeys(value)
+		}
+		return m
+	case map[string]interface{}:
+		for k, value := range t {
+			t[k] = stringKeys(value)
+		}
+		return t
+	case []interface{}:
+		for i, value := range t {
+			t[i] = stringKeys(value)
+		}
+		return t
+	}
+	return v
+}
+
+// GetUnknown returns the value at key, or nil if it doesn't exist. As in all
+// selectors, the key is either a JSON Pointer ("/components/schemas/a~1b", also
+// accepted as an uri fragment starting with "#") or a dotted path ("components.schemas.b").
 func GetUnknown(m map[string]interface{}, key string) interface{} {
 	fieldValue, found := getField(m, key)
 	if !found {
@@ -69,34 +98,87 @@ func GetObject(m map[string]interface{}, key string) map[string]interface{} {
 }
 
 func getField(v interface{}, key string) (interface{}, bool) {
-	segment, key := splitKey(key)
-	if segment == "" {
-		return v, true
+	tokens, err := pathTokens(key)
+	if err != nil {
+		return nil, false
 	}
+	return getTokens(v, tokens)
+}
 
-	switch t := v.(type) {
-	case []interface{}:
-		idx, err := strconv.Atoi(segment)
-		if err != nil || idx >= len(t) || idx < 0 {
+func getTokens(v interface{}, tokens []string) (interface{}, bool) {
+	for _, token := range tokens {
+		switch t := v.(type) {
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx >= len(t) || idx < 0 {
+				return nil, false
+			}
+			v = t[idx]
+
+		case map[string]interface{}:
+			value, found := t[token]
+			if !found {
+				return nil, false
+			}
+			v = value
+
+		default:
 			return nil, false
 		}
-		return getField(t[idx], key)
+	}
+	return v, true
+}
 
-	case map[string]interface{}:
-		v, found := t[segment]
-		if !found {
-			return nil, false
+// pathTokens splits a JSON Pointer or a dotted path into its reference tokens
+func pathTokens(key string) ([]string, error) {
+	if strings.HasPrefix(key, "#") {
+		fragment, err := url.PathUnescape(key[1:])
+		if err != nil {
+			return nil, err
+		}
+		return parsePointer(fragment)
+	}
+
+	if strings.HasPrefix(key, "/") {
+		return parsePointer(key)
+	}
+
+	var tokens []string
+	for _, segment := range strings.Split(key, internalDivider) {
+		if segment != "" {
+			tokens = append(tokens, segment)
+		}
+	}
+	return tokens, nil
+}
+
+// parsePointer parses a JSON Pointer as defined in RFC 6901
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer '%s': must start with '/'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("invalid JSON pointer '%s': bad escape sequence", pointer)
+			}
 		}
-		return getField(v, key)
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
 	}
-	return nil, false
+	return tokens, nil
 }
 
-func splitKey(key string) (string, string) {
-	key = strings.Trim(key, ".")
-	idx := strings.Index(key, ".")
-	if idx == -1 {
-		return key, ""
+// formatPointer is the JSON Pointer made of the reference tokens
+func formatPointer(tokens []string) string {
+	b := strings.Builder{}
+	for _, token := range tokens {
+		b.WriteString("/")
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
 	}
-	return key[:idx], key[idx+1:]
+	return b.String()
 }
@@ -0,0 +1,71 @@
+package js2svg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectorsJSONPointer(t *testing.T) {
+	m := map[string]interface{}{
+		"paths": map[string]interface{}{
+			"/accounts/{id}": map[string]interface{}{"summary": "slash"},
+			"a~b":            map[string]interface{}{"summary": "tilde"},
+			"v1.0":           map[string]interface{}{"summary": "dot"},
+			"with space":     map[string]interface{}{"summary": "space"},
+		},
+		"list": []interface{}{"zero", "one"},
+	}
+
+	assert.Equal(t, "slash", GetString(m, "/paths/~1accounts~1{id}/summary"))
+	assert.Equal(t, "tilde", GetString(m, "/paths/a~0b/summary"))
+	assert.Equal(t, "dot", GetString(m, "/paths/v1.0/summary"))
+	assert.Equal(t, "space", GetString(m, "#/paths/with%20space/summary"))
+	assert.Equal(t, "one", GetString(m, "/list/1"))
+	assert.Equal(t, "one", GetString(m, "list.1"))
+	assert.Equal(t, m, GetUnknown(m, ""))
+	assert.Nil(t, GetUnknown(m, "/paths/a~2b"))
+	assert.Nil(t, GetUnknown(m, "/list/2"))
+}
+
+func TestPointerRoundTrip(t *testing.T) {
+	tokens := []string{"components", "a/b", "c~d", ""}
+	p := formatPointer(tokens)
+	assert.Equal(t, "/components/a~1b/c~0d/", p)
+
+	parsed, err := parsePointer(p)
+	require.NoError(t, err)
+	assert.Equal(t, tokens, parsed)
+
+	_, err = parsePointer("components")
+	assert.Error(t, err)
+}
+
+func TestResolveEscapedReferences(t *testing.T) {
+	doc := `
+components:
+  schemas:
+    v1.Amount:
+      type: object
+      properties:
+        Amount:
+          type: string
+    Payment:
+      type: object
+      properties:
+        Instructed:
+          $ref: '#/components/schemas/v1.Amount'
+        Charge~Amount:
+          $ref: '#/components/schemas/Payment/properties/Instructed'
+`
+	d, err := ParseToDiagram(strings.NewReader(doc), "/components/schemas/Payment")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Payment", d.Root.Name)
+	require.Len(t, d.Root.ComposedOf, 2)
+	assert.Equal(t, "Charge~Amount", d.Root.ComposedOf[0].Object.Name)
+	assert.Len(t, d.Root.ComposedOf[0].Object.Properties, 1)
+	assert.Len(t, d.Root.ComposedOf[1].Object.Properties, 1)
+}
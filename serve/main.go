@@ -105,14 +105,19 @@ func (s *service) registerObjects(w http.ResponseWriter, r *http.Request) {
 	}
 	defer src.Close()
 
-	s.l.Lock()
-	defer s.l.Unlock()
-	schema, err := js2svg.ParseToMap(src, requestData.SchemaPath)
+	// external references are fetched while parsing, without holding the lock
+	opts := js2svg.Options{
+		BaseURI: u.String(),
+		Loader:  js2svg.LoaderFunc(js2svg.LoadHTTP), // never read local files for remote documents
+	}
+	schema, err := js2svg.ParseToMapWithOptions(src, requestData.SchemaPath, opts)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
 		return
 	}
 
+	s.l.Lock()
+	defer s.l.Unlock()
 	s.schemas[requestData.Collection] = schema
 }
 
@@ -131,16 +136,35 @@ func (s *service) renderObject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	m := js2svg.GetObject(schema, objectName)
+	if pointer, ok := js2svg.SchemaPointer(schema, objectName); len(m) == 0 && ok {
+		// a schema of a whole document addressed by name
+		m = js2svg.GetObject(schema, pointer)
+	}
 	// debug(m)
 
-	d, err := js2svg.MakeDiagram(m, objectName) // path here is really just used for naming the root item
+	view, err := js2svg.ParseView(r.URL.Query().Get("view"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := js2svg.Options{
+		Strict:         r.URL.Query().Get("strict") != "",
+		View:           view,
+		HideDeprecated: r.URL.Query().Get("hide-deprecated") != "",
+	}
+	d, err := js2svg.MakeDiagramWithOptions(m, objectName, opts) // path here is really just used for naming the root item
 
 	if err != nil {
 		log.Println(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	for _, diag := range d.Diagnostics {
+		log.Println(&diag)
+	}
 
+	d.InlineTypes = r.URL.Query().Get("types") != ""
 	err = d.Render(w)
 	if err != nil {
 		log.Println(err)